@@ -2,35 +2,181 @@ package cfg
 
 import "m3u8/util"
 
+const (
+	DeadModeKeep    = "keep"
+	DeadModeExclude = "exclude"
+	DeadModeMove    = "move"
+)
+
+const (
+	CensoredModeMark = "mark"
+	CensoredModeDrop = "drop"
+	CensoredModeMove = "move"
+)
+
 type Output struct {
 	FileName   string
 	SkipGroups []string
+	// Lossless keeps original attributes and auxiliary tags of every channel
+	Lossless bool
+
+	// DeadMode channels failed DeadFailures probes in a row are kept, excluded or moved to DeadGroup
+	DeadMode     string
+	DeadFailures int
+	DeadGroup    string
+
+	// CensoredMode parental-control channels are marked censored, dropped or moved to CensoredGroup
+	CensoredMode  string
+	CensoredGroup string
+
+	// Numbering adds stable tvg-chno of group ranges, numbers are stored by NumberingScope
+	Numbering      bool
+	NumberingScope string
+
+	// Include and exclude regexes of group and channel names, empty include matches all
+	IncludeGroups   []string
+	ExcludeGroups   []string
+	IncludeChannels []string
+	ExcludeChannels []string
+	// MinHeight excludes channels of lower or unknown resolution
+	MinHeight int
+	// Providers and ExcludeProviders by provider host or name
+	Providers        []string
+	ExcludeProviders []string
+	// Health allowed probe statuses, "unknown" for not probed channels
+	Health []string
+	// Catchup keeps only channels with archive
+	Catchup bool
+	// Rename output channel names by source name, GroupMap output group names by group name
+	Rename   map[string]string
+	GroupMap map[string]string
 }
 
 func (l *Output) Load(cfg map[string]interface{}) {
 	l.FileName = util.GetValue("file_name", cfg, "")
 	l.SkipGroups = util.GetValueArray("skip_groups", cfg, []string{})
+	l.Lossless = util.GetValue("lossless", cfg, false)
+	l.DeadMode = util.GetValue("dead_mode", cfg, DeadModeKeep)
+	l.DeadFailures = util.GetValue("dead_failures", cfg, 3)
+	l.DeadGroup = util.GetValue("dead_group", cfg, "dead")
+	l.CensoredMode = util.GetValue("censored_mode", cfg, CensoredModeMark)
+	l.CensoredGroup = util.GetValue("censored_group", cfg, "взрослые")
+	l.Numbering = util.GetValue("numbering", cfg, false)
+	l.NumberingScope = util.GetValue("numbering_scope", cfg, l.FileName)
+	l.IncludeGroups = util.GetValueArray("include_groups", cfg, []string{})
+	l.ExcludeGroups = util.GetValueArray("exclude_groups", cfg, []string{})
+	l.IncludeChannels = util.GetValueArray("include_channels", cfg, []string{})
+	l.ExcludeChannels = util.GetValueArray("exclude_channels", cfg, []string{})
+	l.MinHeight = util.GetValue("min_height", cfg, 0)
+	l.Providers = util.GetValueArray("providers", cfg, []string{})
+	l.ExcludeProviders = util.GetValueArray("exclude_providers", cfg, []string{})
+	l.Health = util.GetValueArray("health", cfg, []string{})
+	l.Catchup = util.GetValue("catchup", cfg, false)
+	l.Rename = util.GetValueMap("rename", cfg, map[string]string{})
+	l.GroupMap = util.GetValueMap("group_map", cfg, map[string]string{})
 }
 
+const (
+	ListTypeM3U    = "m3u"
+	ListTypeXtream = "xtream"
+)
+
 type List struct {
+	// Name identifies list in merged outputs
+	Name string
+	// Priority of list channels in merged outputs, higher wins
+	Priority int
+	// Type list source: "m3u" (default) or "xtream" panel api
+	Type    string
 	Url     string
 	EpgUrl  string
 	Outputs []Output
+
+	// Strict stops list processing at first broken line
+	Strict bool
+	// ReportFile path for parse diagnostics
+	ReportFile string
+	// Encoding forced list encoding, detected from BOM, Content-Type or content if empty
+	Encoding string
+
+	// GroupSources channel group precedence: "extgrp", "group-title"
+	GroupSources []string
+	// FallbackGroup for channels without group
+	FallbackGroup string
+
+	// Xtream panel credentials and stream output format: "ts" or "m3u8"
+	Username       string
+	Password       string
+	StreamFormat   string
+	RemoteIdPrefix string
+	// ShortEpgLimit upcoming programmes of each channel loaded with get_short_epg, zero disables guide requests
+	ShortEpgLimit int
 }
 
 func (l *List) Load(cfg map[string]interface{}) {
+	l.Name = util.GetValue("name", cfg, "")
+	l.Priority = util.GetValue("priority", cfg, 0)
+	l.Type = util.GetValue("type", cfg, ListTypeM3U)
 	l.Url = util.GetValue("url", cfg, "")
 	l.EpgUrl = util.GetValue("epg_url", cfg, "")
+	l.Strict = util.GetValue("strict", cfg, false)
+	l.ReportFile = util.GetValue("report_file", cfg, "")
+	l.Encoding = util.GetValue("encoding", cfg, "")
+	l.GroupSources = util.GetValueArray("group_sources", cfg, []string{})
+	l.FallbackGroup = util.GetValue("fallback_group", cfg, "")
+	l.Username = util.GetValue("username", cfg, "")
+	l.Password = util.GetValue("password", cfg, "")
+	l.StreamFormat = util.GetValue("stream_format", cfg, "ts")
+	l.RemoteIdPrefix = util.GetValue("remote_id_prefix", cfg, "")
+	l.ShortEpgLimit = util.GetValue("short_epg_limit", cfg, 0)
+
+	l.Outputs = loadOutputs(cfg)
+
+	//l.Output = util.GetValueArray("output", cfg, []string{})
+	//l.SkipGroups = util.GetValueArray("skip_groups", cfg, []string{})
+}
 
+func loadOutputs(cfg map[string]interface{}) []Output {
 	outputs := util.GetValueArray("output", cfg, []map[string]interface{}{})
-	l.Outputs = make([]Output, len(outputs), len(outputs))
+	result := make([]Output, len(outputs), len(outputs))
 
 	for i := 0; i < len(outputs); i++ {
-		l.Outputs[i].Load(outputs[i])
+		result[i].Load(outputs[i])
 	}
+	return result
+}
 
-	//l.Output = util.GetValueArray("output", cfg, []string{})
-	//l.SkipGroups = util.GetValueArray("skip_groups", cfg, []string{})
+const (
+	SelectPriority = "priority"
+	SelectQuality  = "quality"
+)
+
+// Merged output fed by several lists, channels matched across lists keep best source as primary
+type Merged struct {
+	Name  string
+	Lists []string
+	// Select best source rule: "priority" of list first or "quality" of stream first
+	Select  string
+	EpgUrl  string
+	Outputs []Output
+}
+
+func (m *Merged) Load(cfg map[string]interface{}) {
+	m.Name = util.GetValue("name", cfg, "")
+	m.Lists = util.GetValueArray("lists", cfg, []string{})
+	m.Select = util.GetValue("select", cfg, SelectPriority)
+	m.EpgUrl = util.GetValue("epg_url", cfg, "")
+	m.Outputs = loadOutputs(cfg)
+}
+
+func GetMerged() []*Merged {
+	var result []*Merged
+	for _, item := range util.GetValueArray("merged", conf, []map[string]interface{}{}) {
+		merged := Merged{}
+		merged.Load(item)
+		result = append(result, &merged)
+	}
+	return result
 }
 
 func Load(cfg map[string]interface{}) *List {
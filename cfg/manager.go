@@ -50,9 +50,149 @@ func GetLists() []interface{} {
 	return util.GetValueArray("lists", conf, []interface{}{})
 }
 
+func GetProviders() []interface{} {
+	return util.GetValueArray("providers", conf, []interface{}{})
+}
+
+// GetProber stream prober name: "ffprobe" or "tsprobe"
+func GetProber() string {
+	return util.GetValue("prober", conf, "ffprobe")
+}
+
+func GetProbeBytes() int {
+	return util.GetValue("probe_bytes", conf, 0)
+}
+
+// GetProbeWorkers concurrent stream probes of list
+func GetProbeWorkers() int {
+	return util.GetValue("probe_workers", conf, 8)
+}
+
+// GetProbeHostLimit concurrent stream probes per provider host
+func GetProbeHostLimit() int {
+	return util.GetValue("probe_host_limit", conf, 2)
+}
+
+// GetProbeHostLimits per host overrides of probe_host_limit
+func GetProbeHostLimits() map[string]int {
+	return util.GetValueMap("probe_host_limits", conf, map[string]int{})
+}
+
+// GetMetaTTLHours max age of channel meta in DB or cache before re-probe, 0 disables expiration
+func GetMetaTTLHours() int {
+	return util.GetValue("meta_ttl_hours", conf, 0)
+}
+
+// GetMetaRefreshPercent share of channels re-probed on each daily rotation
+func GetMetaRefreshPercent() int {
+	return util.GetValue("meta_refresh_percent", conf, 0)
+}
+
+// GetMetaCacheFile json file with probe results, disabled if empty
+func GetMetaCacheFile() string {
+	return util.GetValue("meta_cache_file", conf, "")
+}
+
+const (
+	DedupModeOff       = "off"
+	DedupModeDrop      = "drop"
+	DedupModeSeparate  = "separate"
+	DedupModeAttribute = "attribute"
+)
+
+// GetDedupMode duplicate channels handling: off, drop, separate or attribute
+func GetDedupMode() string {
+	return util.GetValue("dedup_mode", conf, DedupModeOff)
+}
+
+// GetDedupBackupAttribute #EXTINF attribute with backup urls for attribute dedup mode
+func GetDedupBackupAttribute() string {
+	return util.GetValue("dedup_backup_attribute", conf, "backup-url")
+}
+
+// GetAliases alternative spellings by canonical channel name
+func GetAliases() map[string][]string {
+	aliases := map[string][]string{}
+	for name, items := range util.GetValueMap("aliases", conf, map[string][]interface{}{}) {
+		for _, item := range items {
+			if alias, ok := item.(string); ok {
+				aliases[name] = append(aliases[name], alias)
+			}
+		}
+	}
+	return aliases
+}
+
+// Parental channels marked censored by group, name regex or EPG age rating
+type Parental struct {
+	// Groups censored groups, group names containing any of them are censored
+	Groups   []string
+	Channels []string
+	// MinAge channels with MinShare percent of EPG airtime rated at least MinAge are censored, 0 disables
+	MinAge   int
+	MinShare int
+	// EpgFile guide file ratings are read from, tvguide epg_path by default
+	EpgFile string
+}
+
+// GetParental parental-control config, "взрослые" group is censored if not configured
+func GetParental() Parental {
+	parentalConf := util.GetValueMap("parental", conf, map[string]interface{}{})
+	if parentalConf == nil {
+		parentalConf = map[string]interface{}{}
+	}
+	return Parental{
+		Groups:   util.GetValueArray("groups", parentalConf, []string{"взрослые"}),
+		Channels: util.GetValueArray("channels", parentalConf, []string{}),
+		MinAge:   util.GetValue("min_age", parentalConf, 0),
+		MinShare: util.GetValue("min_share", parentalConf, 50),
+		EpgFile:  util.GetValue("epg_file", parentalConf, GetTvGuide()["epg_path"]),
+	}
+}
+
+// NumberRange inclusive channel numbers range of group
+type NumberRange struct {
+	From int
+	To   int
+}
+
+func (r NumberRange) Contains(number int) bool {
+	return number >= r.From && number <= r.To
+}
+
+// GetNumberRanges channel number ranges by group name, "HD": [1, 99]
+func GetNumberRanges() map[string]NumberRange {
+	ranges := map[string]NumberRange{}
+	for name, items := range util.GetValueMap("numbering", conf, map[string][]interface{}{}) {
+		if len(items) != 2 {
+			continue
+		}
+		r := NumberRange{From: util.ToType(items[0], 0), To: util.ToType(items[1], 0)}
+		if r.From > 0 && r.To >= r.From {
+			ranges[name] = r
+		}
+	}
+	return ranges
+}
+
+// GetHealthCheck stream of every channel which meta is not probed is requested to detect dead channels, off by default
+func GetHealthCheck() bool {
+	return util.GetValue("health_check", conf, false)
+}
+
+// GetNumberReleaseDays days channel number is kept for channel missing in output, 0 keeps it forever
+func GetNumberReleaseDays() int {
+	return util.GetValue("numbering_release_days", conf, 30)
+}
+
 func GetHDSplit() []string {
 	return util.GetValueArray("group_hd_split", conf, []string{})
 }
+
+// GetQualityTiers default tiers of group_hd_split groups
+func GetQualityTiers() []map[string]interface{} {
+	return util.GetValueArray("quality_tiers", conf, []map[string]interface{}{})
+}
 func GetGroupOrder() []string {
 	return util.GetValueArray("group_order", conf, []string{})
 }
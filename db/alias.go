@@ -0,0 +1,27 @@
+package db
+
+import "errors"
+
+// QueryGetChannelAliases returns canonical channel names by alias spelling
+func QueryGetChannelAliases() (map[string]string, error) {
+	aliases := map[string]string{}
+
+	rows, err := QueryRows(`select alias, name from channel_alias`)
+	if err != nil {
+		return aliases, err
+	}
+	if rows == nil {
+		return aliases, errors.New("failed to fetch channel aliases from DB")
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var alias, name string
+		err = ScanRows(rows, &alias, &name)
+		if err != nil {
+			return aliases, err
+		}
+		aliases[alias] = name
+	}
+	return aliases, nil
+}
@@ -15,13 +15,29 @@ type Channel struct {
 	Width     int
 	Height    int
 	FrameRate int
+	// Probed meta comes from fresh stream probe, updated_at is refreshed on update
+	Probed bool
+
+	// Stream details, kept as is on update while VideoCodec is empty
+	VideoCodec     string
+	VideoProfile   string
+	VideoLevel     int
+	FieldOrder     string
+	PixFmt         string
+	BitRate        int
+	AudioCodecs    []string
+	AudioChannels  []int
+	AudioLanguages []string
+	HasSubtitles   bool
 
 	CreatedAt time.Time
 	UpdatedAt time.Time
 
 	ChannelName ChannelName
+	Health      ChannelHealth
 }
 
+// HasAllMeta stream details are optional, manifest meta or cached rows may have no codec
 func (c *Channel) HasAllMeta() bool {
 	return c.Width != 0 && c.Height != 0 && c.FrameRate != 0
 }
@@ -57,13 +73,25 @@ UPDATE channel c_new set
 width = (case when $2 = 0 then c_old.width else $2 end),
 height = (case when $3 = 0 then c_old.height else $3 end),
 frame_rate = (case when $4 = 0 then c_old.frame_rate else $4 end),
-updated_at = (case when (c_new.width = c_old.width and c_new.height = c_old.height and c_new.frame_rate = c_old.frame_rate) then c_old.updated_at else now() end)
+video_codec = (case when $5 = '' then c_old.video_codec else $5 end),
+video_profile = (case when $5 = '' then c_old.video_profile else $6 end),
+video_level = (case when $5 = '' then c_old.video_level else $7 end),
+field_order = (case when $5 = '' then c_old.field_order else $8 end),
+pix_fmt = (case when $5 = '' then c_old.pix_fmt else $9 end),
+bit_rate = (case when $10 = 0 then c_old.bit_rate else $10 end),
+audio_codecs = (case when $5 = '' then c_old.audio_codecs else coalesce($11::text[], '{}') end),
+audio_channels = (case when $5 = '' then c_old.audio_channels else coalesce($12::integer[], '{}') end),
+audio_languages = (case when $5 = '' then c_old.audio_languages else coalesce($13::text[], '{}') end),
+has_subtitles = (case when $5 = '' then c_old.has_subtitles else $14 end),
+updated_at = (case when $15 then now() else c_old.updated_at end)
     from existing_channel c_old
     WHERE c_new.id = c_old.id
     returning c_new.id, c_new.created_at, c_new.updated_at, to_jsonb(c_old) as old, to_jsonb(c_new) as new),
 inserted_channel AS (
- INSERT INTO channel(remote_id, width, height, frame_rate)
-     SELECT $1, $2, $3, $4
+ INSERT INTO channel(remote_id, width, height, frame_rate, video_codec, video_profile, video_level, field_order, pix_fmt, bit_rate,
+                     audio_codecs, audio_channels, audio_languages, has_subtitles)
+     SELECT $1, $2, $3, $4, $5, $6, $7, $8, $9, $10,
+            coalesce($11::text[], '{}'), coalesce($12::integer[], '{}'), coalesce($13::text[], '{}'), $14
      WHERE NOT EXISTS (SELECT uuc.id FROM updated_channel uuc)
      returning id, created_at, updated_at, null::jsonb as old, to_jsonb(channel) as new)
 SELECT ic.id, ic.created_at, ic.updated_at, ic.old, ic.new
@@ -71,7 +99,9 @@ FROM   inserted_channel ic
 UNION  ALL
 SELECT uc.id, uc.created_at, uc.updated_at, uc.old, uc.new
 FROM updated_channel uc
-limit 1;`, channel.RemoteId, channel.Width, channel.Height, channel.FrameRate)
+limit 1;`, channel.RemoteId, channel.Width, channel.Height, channel.FrameRate,
+		channel.VideoCodec, channel.VideoProfile, channel.VideoLevel, channel.FieldOrder, channel.PixFmt, channel.BitRate,
+		channel.AudioCodecs, channel.AudioChannels, channel.AudioLanguages, channel.HasSubtitles, channel.Probed)
 
 	if err != nil {
 		log.Println(err)
@@ -177,9 +207,13 @@ func QueryGetChannelInfo(remoteId string, provider *Provider) (*Channel, error)
 	}
 
 	row, err := QueryRow(`SELECT c.id, c.width, c.height, c.frame_rate, c.created_at, c.updated_at, c.tvg_name,
-cn.id, cn.name, cn.history_days, cn.group_origin, cn.created_at, cn.updated_at, p.id, p.name
+c.video_codec, c.video_profile, c.video_level, c.field_order, c.pix_fmt, c.bit_rate,
+c.audio_codecs, c.audio_channels, c.audio_languages, c.has_subtitles,
+cn.id, cn.name, cn.history_days, cn.group_origin, cn.created_at, cn.updated_at, p.id, p.name,
+h.status, h.consecutive_failures, h.last_ok_at, h.checked_at
 from channel c
 left join providers p on p.host = $2
+left join channel_health h on h.channel_id = c.id
 left join channel_name cn on c.id = cn.channel_id and cn.provider_id = p.id
 where c.remote_id = $1
 order by c.id, cn.updated_at DESC NULLS LAST limit 1;`, remoteId, provider.Host)
@@ -195,8 +229,11 @@ order by c.id, cn.updated_at DESC NULLS LAST limit 1;`, remoteId, provider.Host)
 
 	channel := Channel{RemoteId: remoteId, ChannelName: ChannelName{Provider: *provider}}
 	err = ScanRow(row, &channel.Id, &channel.Width, &channel.Height, &channel.FrameRate, &channel.CreatedAt, &channel.UpdatedAt, &channel.TvgName,
+		&channel.VideoCodec, &channel.VideoProfile, &channel.VideoLevel, &channel.FieldOrder, &channel.PixFmt, &channel.BitRate,
+		&channel.AudioCodecs, &channel.AudioChannels, &channel.AudioLanguages, &channel.HasSubtitles,
 		&channel.ChannelName.Id, &channel.ChannelName.Name, &channel.ChannelName.HistoryDays, &channel.ChannelName.Group, &channel.ChannelName.CreatedAt, &channel.ChannelName.UpdatedAt,
-		&channel.ChannelName.Provider.Id, &channel.ChannelName.Provider.Name)
+		&channel.ChannelName.Provider.Id, &channel.ChannelName.Provider.Name,
+		&channel.Health.Status, &channel.Health.ConsecutiveFailures, &channel.Health.LastOkAt, &channel.Health.CheckedAt)
 
 	if errors.Is(err, pgx.ErrNoRows) {
 		return nil, nil
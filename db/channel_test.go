@@ -82,3 +82,12 @@ func TestQueryGetChannelInfo(t *testing.T) {
 
 	WaitAllComplete()
 }
+
+func TestChannelHasAllMeta(t *testing.T) {
+	if !(&Channel{Width: 1920, Height: 1080, FrameRate: 25}).HasAllMeta() {
+		t.Fatalf("channel without codec must not be probed again")
+	}
+	if (&Channel{Width: 1920, Height: 1080}).HasAllMeta() {
+		t.Fatalf("channel without frame rate must be probed")
+	}
+}
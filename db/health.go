@@ -0,0 +1,50 @@
+package db
+
+import (
+	"errors"
+	"time"
+)
+
+// ChannelHealth last probe outcome of channel stream
+type ChannelHealth struct {
+	Status              string
+	ConsecutiveFailures int
+	LastOkAt            time.Time
+	CheckedAt           time.Time
+}
+
+// HealthStatusOk probe status resetting consecutive failures
+const HealthStatusOk = "ok"
+
+// QueryAddProbeResult logs probe outcome and updates channel health
+func QueryAddProbeResult(channelId int64, status string, message string) (*ChannelHealth, error) {
+	if channelId == 0 {
+		return nil, errors.New("zero channel id")
+	}
+
+	row, err := QueryRow(`with probe AS (
+    INSERT INTO channel_probe(channel_id, status, message) VALUES ($1, $2, $3)
+    returning channel_id, status, checked_at)
+INSERT INTO channel_health(channel_id, status, consecutive_failures, last_ok_at, checked_at)
+SELECT p.channel_id, p.status, case when p.status = $4 then 0 else 1 end, case when p.status = $4 then p.checked_at end, p.checked_at
+FROM probe p
+on conflict(channel_id) do update set status = excluded.status,
+    consecutive_failures = case when excluded.status = $4 then 0 else channel_health.consecutive_failures + 1 end,
+    last_ok_at = coalesce(excluded.last_ok_at, channel_health.last_ok_at),
+    checked_at = excluded.checked_at
+returning status, consecutive_failures, last_ok_at, checked_at;`, channelId, status, message, HealthStatusOk)
+
+	if row == nil {
+		if err == nil {
+			return nil, errors.New("failed to add probe result")
+		}
+		return nil, err
+	}
+
+	health := ChannelHealth{}
+	err = ScanRow(row, &health.Status, &health.ConsecutiveFailures, &health.LastOkAt, &health.CheckedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &health, nil
+}
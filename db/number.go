@@ -0,0 +1,66 @@
+package db
+
+import (
+	"errors"
+	"time"
+)
+
+// ChannelNumber stored channel number of numbering scope, Key is normalized channel name
+type ChannelNumber struct {
+	Key       string
+	Canonical string
+	Number    int
+	// UpdatedAt last time channel was numbered
+	UpdatedAt time.Time
+}
+
+// QueryGetChannelNumbers returns stored channel numbers of scope
+func QueryGetChannelNumbers(scope string) ([]ChannelNumber, error) {
+	var numbers []ChannelNumber
+
+	rows, err := QueryRows(`select channel_key, canonical, number, updated_at from channel_number where scope = $1 order by number`, scope)
+	if err != nil {
+		return numbers, err
+	}
+	if rows == nil {
+		return numbers, errors.New("failed to fetch channel numbers from DB")
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var number ChannelNumber
+		err = ScanRows(rows, &number.Key, &number.Canonical, &number.Number, &number.UpdatedAt)
+		if err != nil {
+			return numbers, err
+		}
+		numbers = append(numbers, number)
+	}
+	return numbers, nil
+}
+
+// QuerySetChannelNumber stores channel number of scope, previous number of channel key and
+// previous owner of number are replaced
+func QuerySetChannelNumber(scope string, number ChannelNumber) error {
+	_, err := Exec(`delete from channel_number where scope = $1 and (channel_key = $2 or number = $3)`, scope, number.Key, number.Number)
+	if err != nil {
+		return err
+	}
+	_, err = Exec(`insert into channel_number(scope, channel_key, canonical, number) values ($1, $2, $3, $4)`,
+		scope, number.Key, number.Canonical, number.Number)
+	return err
+}
+
+// QueryTouchChannelNumbers refreshes updated_at of numbers kept by channels of scope
+func QueryTouchChannelNumbers(scope string, keys []string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	_, err := Exec(`update channel_number set updated_at = now() where scope = $1 and channel_key = any($2)`, scope, keys)
+	return err
+}
+
+// QueryReleaseChannelNumbers deletes numbers of scope not updated since before
+func QueryReleaseChannelNumbers(scope string, before time.Time) error {
+	_, err := Exec(`delete from channel_number where scope = $1 and updated_at < $2`, scope, before)
+	return err
+}
@@ -3,7 +3,6 @@ package db
 import (
 	"errors"
 	log "github.com/sirupsen/logrus"
-	"strings"
 )
 
 type Provider struct {
@@ -15,24 +14,6 @@ type Provider struct {
 	AccessKey string
 }
 
-func (p *Provider) FromUri(host string, path []string) {
-
-	// http://wkejhfk.rossteleccom.net/iptv/ABCD3HG7DW38ZD/205/index.m3u8
-	// host + / + "iptv" + / + key + / + channel_id + / + file
-
-	args := strings.Split(host, ".")
-	p.SubDomain = args[0]
-	if len(args) > 1 {
-		p.Host = strings.Join(args[1:], ".")
-	} else {
-		p.Host = host
-	}
-
-	if len(path) >= 3 {
-		p.AccessKey = path[2]
-	}
-}
-
 func QueryInsertOrUpdateProvider(provider *Provider) error {
 	if provider == nil {
 		return errors.New("empty provider data")
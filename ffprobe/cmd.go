@@ -2,11 +2,15 @@ package ffprobe
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"fmt"
 	log "github.com/sirupsen/logrus"
-	utils "m3u8/util"
+	"m3u8/semaphore"
+	"m3u8/util"
 	"math"
 	"os/exec"
+	"regexp"
 	"strconv"
 	"strings"
 	"sync"
@@ -130,10 +134,27 @@ type Fraction struct {
 	Quotient float64 `json:"quotient"`
 }
 
+func NewFraction(dividend int, divisor int) Fraction {
+	v := Fraction{
+		Value:    strconv.Itoa(dividend) + "/" + strconv.Itoa(divisor),
+		Dividend: dividend,
+		Divisor:  divisor,
+	}
+	if divisor != 0 {
+		v.Quotient = float64(dividend) / float64(divisor)
+	}
+	return v
+}
+
 func (v *Fraction) RoundedQuotient() int {
 	return int(math.Round(v.Quotient))
 }
 
+// MarshalJSON writes fraction as ffprobe does, e.g. "25/1"
+func (v Fraction) MarshalJSON() ([]byte, error) {
+	return json.Marshal(v.Value)
+}
+
 func (v *Fraction) UnmarshalJSON(data []byte) error {
 
 	err := json.Unmarshal(data, &v.Value)
@@ -153,18 +174,49 @@ func (v *Fraction) UnmarshalJSON(data []byte) error {
 type StreamData struct {
 	Index       int    `json:"index,omitempty"`
 	CodecType   string `json:"codec_type,omitempty"`
+	CodecName   string `json:"codec_name,omitempty"`
+	Profile     string `json:"profile,omitempty"`
+	Level       int    `json:"level,omitempty"`
 	Width       int    `json:"width,omitempty"`
 	Height      int    `json:"height,omitempty"`
 	CodedWidth  int    `json:"coded_width,omitempty"`
 	CodedHeight int    `json:"coded_height,omitempty"`
+	PixFmt      string `json:"pix_fmt,omitempty"`
+	// FieldOrder "progressive", interlaced: "tt", "bb", "tb", "bt"
+	FieldOrder string `json:"field_order,omitempty"`
+	BitRate    string `json:"bit_rate,omitempty"`
+
+	SampleRate    string `json:"sample_rate,omitempty"`
+	Channels      int    `json:"channels,omitempty"`
+	ChannelLayout string `json:"channel_layout,omitempty"`
 
 	RFrameRate   Fraction `json:"r_frame_rate,omitempty"`
 	AVGFrameRate Fraction `json:"avg_frame_rate,omitempty"`
+
+	Tags map[string]string `json:"tags,omitempty"`
+}
+
+func (s *StreamData) IsInterlaced() bool {
+	switch s.FieldOrder {
+	case "tt", "bb", "tb", "bt":
+		return true
+	}
+	return false
+}
+
+func (s *StreamData) GetBitRate() int {
+	bitRate, _ := strconv.Atoi(s.BitRate)
+	return bitRate
+}
+
+func (s *StreamData) Language() string {
+	return s.Tags["language"]
 }
 
 type FormatData struct {
 	FileName string `json:"filename,omitempty"`
-	Format   string `json:"format,omitempty"`
+	Format   string `json:"format_name,omitempty"`
+	BitRate  string `json:"bit_rate,omitempty"`
 }
 
 type MetaData struct {
@@ -190,12 +242,39 @@ func (m *MetaData) GetVideoStream() *StreamData {
 	return nil
 }
 
+func (m *MetaData) GetAudioStreams() []StreamData {
+	var streams []StreamData
+	for _, stream := range m.Streams {
+		if stream.CodecType == "audio" {
+			streams = append(streams, stream)
+		}
+	}
+	return streams
+}
+
+func (m *MetaData) HasSubtitles() bool {
+	for _, stream := range m.Streams {
+		if stream.CodecType == "subtitle" {
+			return true
+		}
+	}
+	return false
+}
+
+// GetBitRate video stream bit rate, whole stream bit rate if video one is unknown
+func (m *MetaData) GetBitRate() int {
+	if vidStream := m.GetVideoStream(); vidStream != nil && vidStream.GetBitRate() != 0 {
+		return vidStream.GetBitRate()
+	}
+	bitRate, _ := strconv.Atoi(m.Format.BitRate)
+	return bitRate
+}
+
 type metaManager struct {
 	meta      map[string]*MetaData
 	metaMutex sync.Mutex
 
-	pendingLoad  []string
-	pendingMutex sync.Mutex
+	pending semaphore.SingleFlight[*MetaData]
 }
 
 func (c *metaManager) getMeta(remoteId string) *MetaData {
@@ -225,72 +304,81 @@ func (c *metaManager) addMeta(remoteId string, data *MetaData) {
 	c.meta[remoteId] = data
 }
 
-func (c *metaManager) removePending(channelRemoteId string) {
-	c.pendingMutex.Lock()
-	defer c.pendingMutex.Unlock()
+var channelMeta metaManager
 
-	c.pendingLoad = utils.Remove(c.pendingLoad, channelRemoteId, false)
+// LoadMetaData probes stream with ffprobe binary, errors are logged
+func LoadMetaData(channelRemoteId string, url string) *MetaData {
+	metaData, err := (&Prober{}).Probe(context.Background(), channelRemoteId, url)
+	if err != nil {
+		log.Printf("Failed to get file info: %v", err)
+	}
+	return metaData
 }
 
-func (c *metaManager) isPending(channelRemoteId string) bool {
-	c.pendingMutex.Lock()
-	defer c.pendingMutex.Unlock()
-
-	return utils.Contains(c.pendingLoad, channelRemoteId)
+// Prober runs ffprobe binary, results with video dimensions are cached by remote id
+type Prober struct {
+	// Timeout ffprobe execution limit, 30 seconds by default
+	Timeout time.Duration
 }
 
-func (c *metaManager) waitPending(channelRemoteId string) {
-	c.pendingMutex.Lock()
-	//defer c.pendingMutex.Unlock()
+func (p *Prober) Probe(ctx context.Context, channelRemoteId string, url string) (*MetaData, error) {
 
-	for utils.Contains(c.pendingLoad, channelRemoteId) {
-		c.pendingMutex.Unlock()
-		time.Sleep(time.Millisecond * time.Duration(100))
-		c.pendingMutex.Lock()
+	m := channelMeta.getMeta(channelRemoteId)
+	if m != nil {
+		return m, nil
 	}
-	c.pendingLoad = utils.AddIfNotExist(c.pendingLoad, channelRemoteId)
-	c.pendingMutex.Unlock()
+
+	// Concurrent probes of same channel stream share single ffprobe run
+	metaData, err, _ := channelMeta.pending.Do(ctx, channelRemoteId+" "+url, func() (*MetaData, error) {
+		return p.run(ctx, channelRemoteId, url)
+	})
+	return metaData, err
 }
 
-var channelMeta metaManager
+// ffprobe reports http failures as "Server returned 404 Not Found" or "Server returned 5XX Server Error reply"
+var httpErrorRegexp = regexp.MustCompile(`(?:Server returned|HTTP error) ([45])(\d\d|XX)`)
 
-func LoadMetaData(channelRemoteId string, url string) *MetaData {
+func parseHTTPError(stderr string) error {
+	match := httpErrorRegexp.FindStringSubmatch(stderr)
+	if match == nil {
+		return nil
+	}
+	code, _ := strconv.Atoi(match[1] + strings.Replace(match[2], "XX", "00", 1))
+	return &util.HTTPStatusError{StatusCode: code}
+}
 
-	channelMeta.waitPending(channelRemoteId)
-	defer channelMeta.removePending(channelRemoteId)
+func (p *Prober) run(ctx context.Context, channelRemoteId string, url string) (*MetaData, error) {
 
-	m := channelMeta.getMeta(channelRemoteId)
-	if m != nil {
-		return m
+	timeout := p.Timeout
+	if timeout <= 0 {
+		timeout = 30 * time.Second
 	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
 
 	log.Println("Loading:", url)
-	cmd := exec.Command("ffprobe", "-timeout", "20", "-v", "quiet", "-print_format", "json", "-show_streams", "-i", url)
+	cmd := exec.CommandContext(ctx, "ffprobe", "-timeout", "20", "-v", "error", "-print_format", "json", "-show_streams", "-show_format", "-i", url)
 
 	// Use a bytes.Buffer to get the output
-	var buf bytes.Buffer
+	var buf, errBuf bytes.Buffer
 	cmd.Stdout = &buf
+	cmd.Stderr = &errBuf
 
-	//log.Printf("Start cmd: %s", strings.Join(cmd.Args, " "))
-	err := cmd.Start()
+	err := cmd.Run()
 	if err != nil {
-		log.Printf("Failed to get file info: %v", err)
-		return nil
-	}
-
-	//log.Printf("Wait cmd")
-	err = cmd.Wait()
-	if err != nil {
-		log.Printf("Failed to wait till cmd finish: %v", err)
-		return nil
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("ffprobe %s failed: %w", url, ctx.Err())
+		}
+		if statusErr := parseHTTPError(errBuf.String()); statusErr != nil {
+			return nil, fmt.Errorf("ffprobe %s failed: %w", url, statusErr)
+		}
+		return nil, fmt.Errorf("ffprobe %s failed: %v %s", url, err, strings.TrimSpace(errBuf.String()))
 	}
 
 	var metaData MetaData
 	err = json.Unmarshal(buf.Bytes(), &metaData)
-
 	if err != nil {
-		log.Printf("Failed Unmarshall metadata: %v", err)
-		return nil
+		return nil, fmt.Errorf("failed unmarshall metadata: %v", err)
 	}
 
 	vidStream := metaData.GetVideoStream()
@@ -298,5 +386,5 @@ func LoadMetaData(channelRemoteId string, url string) *MetaData {
 		channelMeta.addMeta(channelRemoteId, &metaData)
 	}
 
-	return &metaData
+	return &metaData, nil
 }
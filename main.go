@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	log "github.com/sirupsen/logrus"
 	"io"
 	"m3u8/cfg"
@@ -8,37 +9,135 @@ import (
 	"m3u8/db"
 	"m3u8/meta"
 	"m3u8/xmltv"
+	"m3u8/xtream"
 	"os"
+	"os/signal"
 	"sync"
+	"syscall"
 )
 
 func processChannels(media *meta.Media) {
+	rules, errs := meta.LoadRules()
+	for _, err := range errs {
+		log.Errorf("invalid group rule: %v", err)
+	}
+	// Literal force lists are applied after rules and win over them
+	media.ApplyRules(rules)
 	media.ApplyGroupsForcing()
+	media.Deduplicate(cfg.GetDedupMode(), cfg.GetDedupBackupAttribute())
 	media.SortGroups()
-	media.ValidateHighRes()
+	tiers, errs := meta.LoadQualityTiers()
+	for _, err := range errs {
+		log.Errorf("invalid quality tier: %v", err)
+	}
+	media.SplitQualityTiers(tiers)
 	media.OrderGroups()
 }
 
-func loadPlayList(data *cfg.List, forceReloadChannelData bool, noSampleLoad bool) {
+// loadPlayList reads list with base options shared by all lists, writes its outputs and returns processed media
+func loadPlayList(ctx context.Context, scheduler *meta.ProbeScheduler, data *cfg.List, base meta.ReadOptions) *meta.Media {
 	if data.Url == "" {
-		log.Errorf("invalid url in list")
-		return
+		log.Errorf("invalid url in list, expected http(s) url, file path or \"-\" for stdin")
+		return nil
 	}
 
-	media := meta.ReadUrl(data.Url, forceReloadChannelData, noSampleLoad)
+	opts := &base
+	opts.Strict = data.Strict
+	opts.Encoding = data.Encoding
+	opts.GroupSources = data.GroupSources
+	opts.FallbackGroup = data.FallbackGroup
+	opts.Source = data.Name
+	opts.SourcePriority = data.Priority
+
+	var media *meta.Media
+	var err error
+	switch data.Type {
+	case cfg.ListTypeXtream:
+		media, err = loadXtream(data, opts)
+	default:
+		var report *meta.ParseReport
+		media, report, err = meta.Open(data.Url, opts)
+		writeParseReport(data, report)
+	}
 
-	if media == nil {
-		return
+	if err != nil {
+		log.Errorf("failed to read playlist %s: %v", data.Url, err)
+		return nil
+	}
+
+	err = media.LoadChannels(ctx, scheduler)
+	if err != nil {
+		log.Errorf("channels loading of %s interrupted: %v", data.Url, err)
+		return nil
 	}
 	processChannels(media)
 
-	for _, output := range data.Outputs {
-		media.WriteFile(output.FileName, data.EpgUrl, output.SkipGroups)
+	media.WriteFiles(data.Outputs, data.EpgUrl)
+	return media
+}
+
+// writeMerged writes merged outputs of loaded lists, lists failed to load are skipped
+func writeMerged(medias map[string]*meta.Media) {
+	for _, merged := range cfg.GetMerged() {
+		sources := make([]*meta.Media, 0, len(merged.Lists))
+		for _, name := range merged.Lists {
+			media, ok := medias[name]
+			if !ok {
+				log.Warnf("list %s of merged output %s is not loaded", name, merged.Name)
+				continue
+			}
+			sources = append(sources, media)
+		}
+		if len(sources) == 0 {
+			continue
+		}
+
+		media := meta.Merge(sources, merged.Select, cfg.GetDedupMode(), cfg.GetDedupBackupAttribute())
+		processChannels(media)
+		media.WriteFiles(merged.Outputs, merged.EpgUrl)
+	}
+}
+
+func loadXtream(data *cfg.List, opts *meta.ReadOptions) (*meta.Media, error) {
+	prefix := data.RemoteIdPrefix
+	if prefix == "" {
+		prefix = xtream.DefaultRemoteIdPrefix
 	}
+	client := xtream.NewClient(data.Url, data.Username, data.Password)
+	return xtream.LoadMedia(client, data.StreamFormat, prefix, data.ShortEpgLimit, opts)
 }
 
-func processListConfig() {
+func writeParseReport(data *cfg.List, report *meta.ParseReport) {
+	if report == nil {
+		return
+	}
+	errCount := report.Count(meta.SeverityError)
+	warnCount := report.Count(meta.SeverityWarning)
+	if errCount > 0 || warnCount > 0 {
+		log.Warnf("Playlist %s has %d errors and %d warnings in %d lines", data.Url, errCount, warnCount, report.Lines)
+	}
+
+	if data.ReportFile == "" {
+		return
+	}
+	f, err := os.Create(data.ReportFile)
+	if f != nil {
+		defer f.Close()
+	}
+	if err != nil {
+		log.Errorf("failed to create report file %s: %v", data.ReportFile, err)
+		return
+	}
+	err = report.Write(f, meta.SeverityWarning)
+	if err != nil {
+		log.Errorf("failed to write report file %s: %v", data.ReportFile, err)
+	}
+}
+
+func processListConfig(ctx context.Context) {
 	wg := sync.WaitGroup{}
+	// Shared scheduler keeps per host limits across all lists
+	scheduler := meta.NewProbeScheduler()
 
 	lists := cfg.GetLists()
 	if lists == nil {
@@ -46,23 +145,51 @@ func processListConfig() {
 		return
 	}
 
+	base := meta.ReadOptions{
+		ForceReloadChannelData: cmd.ForceReDownload,
+		NoSampleLoad:           cmd.NoSampleLoad,
+		Freshness:              meta.NewFreshness(),
+		HealthCheck:            cfg.GetHealthCheck(),
+	}
+	if cacheFile := cfg.GetMetaCacheFile(); cacheFile != "" {
+		cache, err := meta.OpenMetaCache(cacheFile)
+		if err != nil {
+			log.Errorf("failed to read meta cache %s: %v", cacheFile, err)
+		}
+		base.MetaCache = cache
+		defer func() {
+			if err := cache.Save(); err != nil {
+				log.Errorf("failed to save meta cache %s: %v", cacheFile, err)
+			}
+		}()
+	}
+
+	// Loaded medias of named lists feed merged outputs
+	medias := map[string]*meta.Media{}
+	mediasMutex := sync.Mutex{}
+
 	for _, item := range lists {
 		switch item.(type) {
 		case map[string]interface{}:
 			wg.Add(1)
-			go processList(&wg, item.(map[string]interface{}))
+			go func(data *cfg.List) {
+				defer wg.Done()
+				media := loadPlayList(ctx, scheduler, data, base)
+				if media != nil && data.Name != "" {
+					mediasMutex.Lock()
+					medias[data.Name] = media
+					mediasMutex.Unlock()
+				}
+			}(cfg.Load(item.(map[string]interface{})))
 		default:
 			break
 		}
 	}
 	wg.Wait()
-}
-
-func processList(wg *sync.WaitGroup, cfgMap map[string]interface{}) {
-	defer wg.Done()
 
-	loadPlayList(cfg.Load(cfgMap), cmd.ForceReDownload,
-		cmd.NoSampleLoad)
+	if ctx.Err() == nil {
+		writeMerged(medias)
+	}
 }
 
 func setupLog(filePath string) {
@@ -98,6 +225,7 @@ func main() {
 	must(cfg.LoadConfig(cmd.ConfFile, cmd.EnvFile))
 
 	must(db.Init(cfg.GetEnvString("DB_URI", "")))
+	meta.LoadAliases()
 
 	if !cmd.NoTvGuide {
 		err = xmltv.GenerateTvGuideFromUrl(cfg.GetTvGuide())
@@ -105,9 +233,14 @@ func main() {
 			log.Errorf("Failed to generate Tv Guide")
 		}
 	}
+	// EPG ratings are read from guide generated above
+	meta.LoadParental()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
 	log.Println("Processing play lists...")
-	processListConfig()
+	processListConfig(ctx)
 	log.Println("Completed!")
 
 	// Wait till all async DB Queries complete
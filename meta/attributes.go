@@ -0,0 +1,296 @@
+package meta
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Attribute single key="value" pair from #EXTM3U or #EXTINF line
+type Attribute struct {
+	Key   string
+	Value string
+}
+
+// Attributes ordered attribute list, lookup by key is case-insensitive
+type Attributes struct {
+	items []Attribute
+}
+
+func (a *Attributes) index(key string) int {
+	if a == nil {
+		return -1
+	}
+	for i := 0; i < len(a.items); i++ {
+		if strings.EqualFold(a.items[i].Key, key) {
+			return i
+		}
+	}
+	return -1
+}
+
+func (a *Attributes) Get(key string) (string, bool) {
+	i := a.index(key)
+	if i < 0 {
+		return "", false
+	}
+	return a.items[i].Value, true
+}
+
+func (a *Attributes) Value(key string) string {
+	val, _ := a.Get(key)
+	return val
+}
+
+// First returns value of first existing key from list
+func (a *Attributes) First(keys ...string) string {
+	for _, key := range keys {
+		if val, ok := a.Get(key); ok {
+			return val
+		}
+	}
+	return ""
+}
+
+func (a *Attributes) Int(key string, defVal int) int {
+	val, ok := a.Get(key)
+	if !ok {
+		return defVal
+	}
+	intVal, err := strconv.ParseInt(strings.TrimSpace(val), 10, 32)
+	if err != nil {
+		return defVal
+	}
+	return int(intVal)
+}
+
+func (a *Attributes) Has(key string) bool {
+	return a.index(key) >= 0
+}
+
+// Set replaces existing value keeping its position or appends new attribute
+func (a *Attributes) Set(key string, value string) {
+	i := a.index(key)
+	if i >= 0 {
+		a.items[i].Value = value
+		return
+	}
+	a.items = append(a.items, Attribute{Key: key, Value: value})
+}
+
+func (a *Attributes) Delete(key string) {
+	i := a.index(key)
+	if i >= 0 {
+		a.items = append(a.items[:i], a.items[i+1:]...)
+	}
+}
+
+func (a *Attributes) Len() int {
+	if a == nil {
+		return 0
+	}
+	return len(a.items)
+}
+
+func (a *Attributes) Keys() []string {
+	keys := make([]string, 0, a.Len())
+	for i := 0; i < a.Len(); i++ {
+		keys = append(keys, a.items[i].Key)
+	}
+	return keys
+}
+
+func (a *Attributes) Items() []Attribute {
+	items := make([]Attribute, a.Len())
+	if a != nil {
+		copy(items, a.items)
+	}
+	return items
+}
+
+func (a *Attributes) Clone() *Attributes {
+	return &Attributes{items: a.Items()}
+}
+
+// String formats attributes back to key="value" list separated with space
+func (a *Attributes) String() string {
+	var sb strings.Builder
+	for i := 0; i < a.Len(); i++ {
+		if i > 0 {
+			sb.WriteByte(' ')
+		}
+		sb.WriteString(a.items[i].Key)
+		sb.WriteString(`="`)
+		sb.WriteString(escapeAttribute(a.items[i].Value))
+		sb.WriteByte('"')
+	}
+	return sb.String()
+}
+
+func escapeAttribute(value string) string {
+	if !strings.ContainsAny(value, `"\`) {
+		return value
+	}
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value)
+}
+
+// ChannelInfo typed view of known #EXTINF attributes
+type ChannelInfo struct {
+	TvgId         string
+	TvgName       string
+	TvgLogo       string
+	TvgChno       int
+	TvgShift      string
+	TvgRec        int
+	GroupTitle    string
+	Catchup       string
+	CatchupSource string
+	CatchupDays   int
+	Timeshift     int
+	UserAgent     string
+	Referrer      string
+	Censored      bool
+}
+
+func newChannelInfo(attributes *Attributes) ChannelInfo {
+	return ChannelInfo{
+		TvgId:         attributes.Value("tvg-id"),
+		TvgName:       attributes.Value("tvg-name"),
+		TvgLogo:       attributes.First("tvg-logo", "logo"),
+		TvgChno:       attributes.Int("tvg-chno", 0),
+		TvgShift:      attributes.Value("tvg-shift"),
+		TvgRec:        attributes.Int("tvg-rec", 0),
+		GroupTitle:    attributes.Value("group-title"),
+		Catchup:       attributes.Value("catchup"),
+		CatchupSource: attributes.Value("catchup-source"),
+		CatchupDays:   attributes.Int("catchup-days", 0),
+		Timeshift:     attributes.Int("timeshift", 0),
+		UserAgent:     attributes.First("user-agent", "http-user-agent"),
+		Referrer:      attributes.First("http-referrer", "referrer"),
+		Censored:      attributes.Int("censored", 0) != 0,
+	}
+}
+
+// ExtInf parsed content of #EXTINF line
+type ExtInf struct {
+	Duration   string
+	Attributes *Attributes
+	Title      string
+}
+
+// ParseExtInf parses data after "#EXTINF:" prefix
+// #EXTINF:0 tvg-rec="0",минимакс-воронины HD
+// #EXTINF:-1 tvg-id="1" group-title="Кино, HD" tvg-logo="http://...",Disney Channel
+func ParseExtInf(data string) ExtInf {
+	head, title, _ := splitTitle(data)
+	head = strings.TrimSpace(head)
+
+	duration := head
+	attributes := ""
+	if i := strings.IndexAny(head, " \t"); i >= 0 {
+		duration = head[:i]
+		attributes = head[i+1:]
+	}
+	// Duration is mandatory, but some lists start straight with attributes
+	if strings.Contains(duration, "=") {
+		duration = ""
+		attributes = head
+	}
+
+	return ExtInf{
+		Duration:   duration,
+		Attributes: ParseAttributes(attributes),
+		Title:      strings.TrimSpace(title),
+	}
+}
+
+// splitTitle splits data at first comma which is not quoted
+func splitTitle(data string) (string, string, bool) {
+	quote := byte(0)
+	for i := 0; i < len(data); i++ {
+		switch ch := data[i]; {
+		case quote != 0 && ch == '\\':
+			i++
+		case quote != 0 && ch == quote:
+			quote = 0
+		case quote == 0 && (ch == '"' || ch == '\''):
+			// Quote could start value only right after "="
+			if i > 0 && data[i-1] == '=' {
+				quote = ch
+			}
+		case quote == 0 && ch == ',':
+			return data[:i], data[i+1:], true
+		}
+	}
+	return data, "", false
+}
+
+// ParseAttributes parses space separated key="value" list,
+// values could be quoted with " or ', or unquoted till next whitespace
+func ParseAttributes(data string) *Attributes {
+	attributes := &Attributes{}
+
+	i := 0
+	for i < len(data) {
+		for i < len(data) && isSpace(data[i]) {
+			i++
+		}
+		start := i
+		for i < len(data) && data[i] != '=' && !isSpace(data[i]) {
+			i++
+		}
+		key := data[start:i]
+		if i >= len(data) || data[i] != '=' {
+			// Flag without value
+			if key != "" {
+				attributes.items = append(attributes.items, Attribute{Key: key})
+			}
+			continue
+		}
+		i++
+
+		var value string
+		value, i = readValue(data, i, isSpace)
+		if key != "" {
+			attributes.items = append(attributes.items, Attribute{Key: key, Value: value})
+		}
+	}
+	return attributes
+}
+
+// readValue reads quoted or unquoted value started at pos, returns value and position after it
+func readValue(data string, pos int, isSeparator func(byte) bool) (string, int) {
+	if pos >= len(data) {
+		return "", pos
+	}
+
+	quote := data[pos]
+	if quote != '"' && quote != '\'' {
+		start := pos
+		for pos < len(data) && !isSeparator(data[pos]) {
+			pos++
+		}
+		return data[start:pos], pos
+	}
+
+	var sb strings.Builder
+	pos++
+	for pos < len(data) {
+		ch := data[pos]
+		if ch == '\\' && pos+1 < len(data) && (data[pos+1] == quote || data[pos+1] == '\\') {
+			sb.WriteByte(data[pos+1])
+			pos += 2
+			continue
+		}
+		if ch == quote {
+			pos++
+			break
+		}
+		sb.WriteByte(ch)
+		pos++
+	}
+	return sb.String(), pos
+}
+
+func isSpace(ch byte) bool {
+	return ch == ' ' || ch == '\t'
+}
@@ -0,0 +1,62 @@
+package meta
+
+import "testing"
+
+func TestParseExtInf(t *testing.T) {
+	extInf := ParseExtInf(`-1 tvg-id="kino.ru" tvg-name="Кино HD" group-title="Кино, HD" tvg-logo=http://logo/1.png catchup-days="5" title="say \"hi\"",Кино HD`)
+
+	if extInf.Duration != "-1" {
+		t.Fatalf("invalid duration: %s", extInf.Duration)
+	}
+	if extInf.Title != "Кино HD" {
+		t.Fatalf("invalid title: %s", extInf.Title)
+	}
+
+	expected := []Attribute{
+		{Key: "tvg-id", Value: "kino.ru"},
+		{Key: "tvg-name", Value: "Кино HD"},
+		{Key: "group-title", Value: "Кино, HD"},
+		{Key: "tvg-logo", Value: "http://logo/1.png"},
+		{Key: "catchup-days", Value: "5"},
+		{Key: "title", Value: `say "hi"`},
+	}
+	items := extInf.Attributes.Items()
+	if len(items) != len(expected) {
+		t.Fatalf("invalid attributes count: %v", items)
+	}
+	for i := range expected {
+		if items[i] != expected[i] {
+			t.Fatalf("attribute %d: expected %v, got %v", i, expected[i], items[i])
+		}
+	}
+
+	info := newChannelInfo(extInf.Attributes)
+	if info.GroupTitle != "Кино, HD" || info.CatchupDays != 5 || info.TvgLogo != "http://logo/1.png" {
+		t.Fatalf("invalid channel info: %+v", info)
+	}
+}
+
+func TestParseExtInfLegacy(t *testing.T) {
+	extInf := ParseExtInf(` 0 catchup="default" catchup-days="5", Disney Channel`)
+	if extInf.Duration != "0" || extInf.Title != "Disney Channel" {
+		t.Fatalf("invalid ext inf: %+v", extInf)
+	}
+	if extInf.Attributes.Value("CATCHUP") != "default" {
+		t.Fatalf("case insensitive lookup failed")
+	}
+
+	extInf = ParseExtInf(`0,Первый HD`)
+	if extInf.Duration != "0" || extInf.Title != "Первый HD" || extInf.Attributes.Len() != 0 {
+		t.Fatalf("invalid ext inf: %+v", extInf)
+	}
+}
+
+func TestAttributesString(t *testing.T) {
+	attributes := ParseAttributes(`tvg-name="A \"B\"" group-title='Кино'`)
+	attributes.Set("censored", "1")
+	attributes.Set("tvg-name", "C")
+
+	if s := attributes.String(); s != `tvg-name="C" group-title="Кино" censored="1"` {
+		t.Fatalf("unexpected attributes string: %s", s)
+	}
+}
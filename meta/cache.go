@@ -0,0 +1,159 @@
+package meta
+
+import (
+	"encoding/json"
+	"errors"
+	"hash/fnv"
+	"m3u8/cfg"
+	"m3u8/ffprobe"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Freshness decides when stored channel meta has to be probed again
+type Freshness struct {
+	// TTL max age of stored meta, zero disables expiration
+	TTL time.Duration
+	// RefreshPercent share of channels re-probed on each day regardless of age,
+	// the slice rotates daily so all channels are refreshed in 100/RefreshPercent days;
+	// meta probed on current day is kept so several runs a day probe slice once
+	RefreshPercent int
+
+	now func() time.Time
+}
+
+func NewFreshness() *Freshness {
+	return &Freshness{
+		TTL:            time.Duration(cfg.GetMetaTTLHours()) * time.Hour,
+		RefreshPercent: cfg.GetMetaRefreshPercent(),
+	}
+}
+
+func (f *Freshness) getNow() time.Time {
+	if f.now == nil {
+		return time.Now()
+	}
+	return f.now()
+}
+
+// IsStale true if meta loaded at updatedAt must be probed again, nil policy never expires
+func (f *Freshness) IsStale(remoteId string, updatedAt time.Time) bool {
+	if f == nil {
+		return false
+	}
+	now := f.getNow()
+	if f.TTL > 0 && !updatedAt.IsZero() && now.Sub(updatedAt) > f.TTL {
+		return true
+	}
+	if f.RefreshPercent <= 0 {
+		return false
+	}
+	if f.RefreshPercent >= 100 {
+		return true
+	}
+
+	day := int(now.Unix() / int64(24*time.Hour/time.Second))
+	if !updatedAt.IsZero() && int(updatedAt.Unix()/int64(24*time.Hour/time.Second)) == day {
+		return false
+	}
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(remoteId))
+	bucket := int(h.Sum32() % 100)
+	start := day * f.RefreshPercent % 100
+	return (bucket-start+100)%100 < f.RefreshPercent
+}
+
+type cacheEntry struct {
+	Url      string            `json:"url"`
+	ProbedAt time.Time         `json:"probed_at"`
+	MetaData *ffprobe.MetaData `json:"meta"`
+}
+
+// MetaCache probe results by remote id persisted in json file, keeps meta for runs without DB
+type MetaCache struct {
+	path    string
+	entries map[string]cacheEntry
+	changed bool
+	mutex   sync.Mutex
+}
+
+// OpenMetaCache loads cache file, missing file gives empty cache
+func OpenMetaCache(path string) (*MetaCache, error) {
+	cache := &MetaCache{path: path, entries: map[string]cacheEntry{}}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cache, nil
+	}
+	if err != nil {
+		return cache, err
+	}
+	if err = json.Unmarshal(data, &cache.entries); err != nil {
+		return cache, err
+	}
+	if cache.entries == nil {
+		cache.entries = map[string]cacheEntry{}
+	}
+	return cache, nil
+}
+
+// Get returns cached meta and its probe time
+func (c *MetaCache) Get(remoteId string) (*ffprobe.MetaData, time.Time, bool) {
+	if c == nil {
+		return nil, time.Time{}, false
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	entry, ok := c.entries[remoteId]
+	if !ok || entry.MetaData == nil {
+		return nil, time.Time{}, false
+	}
+	return entry.MetaData, entry.ProbedAt, true
+}
+
+func (c *MetaCache) Put(remoteId string, streamUrl string, metaData *ffprobe.MetaData) {
+	if c == nil || remoteId == "" || metaData == nil {
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.entries[remoteId] = cacheEntry{Url: streamUrl, ProbedAt: time.Now(), MetaData: metaData}
+	c.changed = true
+}
+
+// Save writes cache file if it was changed, file is replaced atomically
+func (c *MetaCache) Save() error {
+	if c == nil || c.path == "" {
+		return nil
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if !c.changed {
+		return nil
+	}
+	data, err := json.Marshal(c.entries)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(c.path), filepath.Base(c.path)+".*")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), c.path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	c.changed = false
+	return nil
+}
@@ -0,0 +1,109 @@
+package meta
+
+import (
+	"m3u8/db"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestFreshness(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	f := &Freshness{TTL: 24 * time.Hour, now: func() time.Time { return now }}
+
+	if !f.IsStale("1", now.Add(-25*time.Hour)) || f.IsStale("1", now.Add(-time.Hour)) {
+		t.Fatalf("unexpected ttl expiration")
+	}
+	if (*Freshness)(nil).IsStale("1", time.Time{}) {
+		t.Fatalf("nil policy must never expire")
+	}
+
+	f = &Freshness{RefreshPercent: 10, now: func() time.Time { return now }}
+	counts := map[string]int{}
+	for day := 0; day < 10; day++ {
+		stale := 0
+		for i := 0; i < 1000; i++ {
+			remoteId := strconv.Itoa(i)
+			if f.IsStale(remoteId, now.Add(-24*time.Hour)) {
+				stale++
+				counts[remoteId]++
+				// Second run of same day keeps meta probed by first one
+				if f.IsStale(remoteId, now) {
+					t.Fatalf("channel %s probed today must not be refreshed again", remoteId)
+				}
+			}
+		}
+		if stale < 50 || stale > 150 {
+			t.Fatalf("expected about 10%% stale channels on day %d, got %d", day, stale)
+		}
+		now = now.Add(24 * time.Hour)
+	}
+	// Rotation covers every channel exactly once in 10 days
+	for i := 0; i < 1000; i++ {
+		if counts[strconv.Itoa(i)] != 1 {
+			t.Fatalf("channel %d refreshed %d times", i, counts[strconv.Itoa(i)])
+		}
+	}
+}
+
+func TestNeedsProbe(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	f := &Freshness{TTL: 24 * time.Hour, RefreshPercent: 100, now: func() time.Time { return now }}
+	stale := &db.Channel{Width: 1920, Height: 1080, FrameRate: 25, UpdatedAt: now.Add(-48 * time.Hour)}
+
+	if !(&Channel{freshness: f}).needsProbe("1", stale) || !(&Channel{NoSampleLoad: true}).needsProbe("1", nil) {
+		t.Fatalf("stale or unknown channel must be probed")
+	}
+	if (&Channel{freshness: f, NoSampleLoad: true}).needsProbe("1", stale) ||
+		(&Channel{NoSampleLoad: true}).needsProbe("1", &db.Channel{Width: 1920}) {
+		t.Fatalf("no sample run must keep stored meta")
+	}
+	if !(&Channel{NoSampleLoad: true, ForceReloadData: true}).needsProbe("1", stale) {
+		t.Fatalf("forced reload must be probed")
+	}
+}
+
+func TestMetaCache(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "meta_cache.json")
+	cache, err := OpenMetaCache(path)
+	if err != nil {
+		t.Fatalf("missing cache file must not fail: %v", err)
+	}
+
+	streamUrl := "http://host/iptv/key/101/index.m3u8"
+	fake := NewFakeProber().Add(streamUrl, FakeMetaData(1920, 1080, 50))
+	channel := &Channel{Url: streamUrl, prober: fake, metaCache: cache}
+	channel.SetName(`0 tvg-rec="3",Kino HD`, "кино")
+	if channel.Width != 1920 || len(fake.Calls()) != 1 {
+		t.Fatalf("channel must be probed: %+v", channel)
+	}
+	if err = cache.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	cache, err = OpenMetaCache(path)
+	if err != nil {
+		t.Fatalf("OpenMetaCache failed: %v", err)
+	}
+	fake = NewFakeProber()
+	channel = &Channel{Url: streamUrl, prober: fake, metaCache: cache}
+	channel.SetName(`0 tvg-rec="3",Kino HD`, "кино")
+	if channel.Width != 1920 || channel.FrameRate != 50 || len(fake.Calls()) != 0 {
+		t.Fatalf("cached meta must be used without probing: %+v, %v", channel, fake.Calls())
+	}
+
+	// Expired cache entry is probed again
+	stale := &Freshness{TTL: time.Hour, now: func() time.Time { return time.Now().Add(2 * time.Hour) }}
+	channel = &Channel{Url: streamUrl, prober: fake, metaCache: cache, freshness: stale}
+	channel.SetName(`0 tvg-rec="3",Kino HD`, "кино")
+	if len(fake.Calls()) != 1 {
+		t.Fatalf("stale cache entry must be probed")
+	}
+
+	channel = &Channel{Url: streamUrl, prober: fake, metaCache: cache, ForceReloadData: true}
+	channel.SetName(`0 tvg-rec="3",Kino HD`, "кино")
+	if len(fake.Calls()) != 2 {
+		t.Fatalf("forced reload must bypass cache")
+	}
+}
@@ -1,15 +1,16 @@
 package meta
 
 import (
+	"context"
 	"fmt"
 	log "github.com/sirupsen/logrus"
 	"m3u8/db"
 	"m3u8/ffprobe"
-	"m3u8/util"
 	"net/url"
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 )
 
 type Channel struct {
@@ -19,20 +20,81 @@ type Channel struct {
 	infoData    string
 	Url         string
 
+	// Duration from #EXTINF line, "0" or "-1" for live streams
+	Duration string
+	// Attributes all #EXTINF attributes in original order
+	Attributes *Attributes
+	// Info typed known attributes
+	Info ChannelInfo
+	// Tags auxiliary tag lines of record: #EXTVLCOPT, #KODIPROP, #EXTHTTP
+	Tags []string
+
 	HistoryDays int
 	Width       int
 	Height      int
 	FrameRate   int
 
-	//providerHost string
-	//providerName string
+	// Stream details of probed video and audio streams
+	VideoCodec   string
+	Profile      string
+	Level        int
+	FieldOrder   string
+	PixFmt       string
+	BitRate      int
+	AudioTracks  []AudioTrack
+	HasSubtitles bool
+
+	// Programmes upcoming programmes from provider api, empty for m3u lists
+	Programmes []Programme
+
+	// Health last stream probe outcome
+	Health ChannelHealth
+	// Backups duplicates ranked below channel by Media.Deduplicate
+	Backups []*Channel
+
+	// RemoteId and Provider extracted from url by provider url profile
+	RemoteId string
+	Provider db.Provider
+	// Source name of list channel comes from, SourcePriority its priority in merged outputs
+	Source         string
+	SourcePriority int
+	// SourceGroup channel group in source list
+	SourceGroup string
 
 	ForceReloadData bool
 	NoSampleLoad    bool
 
+	prober    Prober
+	freshness *Freshness
+	metaCache *MetaCache
+	// healthCheck checks stream of channel which meta is not probed
+	healthCheck bool
+	// probed meta comes from stream probe of this run
+	probed bool
+
 	meta *Media
 }
 
+type AudioTrack struct {
+	Codec    string
+	Channels int
+	Language string
+}
+
+// Programme short guide entry of channel
+type Programme struct {
+	Title       string
+	Description string
+	Start       time.Time
+	Stop        time.Time
+}
+
+// IsInterlaced true for known interlaced field order, unknown order is taken as progressive
+func (c *Channel) IsInterlaced() bool {
+	stream := ffprobe.StreamData{FieldOrder: c.FieldOrder}
+	return stream.IsInterlaced()
+}
+
 /*
 func (c *Channel) GetProviderHost() string {
 	if c.providerHost != "" {
@@ -55,22 +117,26 @@ func (c *Channel) GetProviderHost() string {
 }
 */
 
-func (c *Channel) setData(data string) {
-	c.infoData = data
+func (c *Channel) setData(extInf ExtInf) {
+	c.Duration = extInf.Duration
+	c.Attributes = extInf.Attributes
+	c.Info = newChannelInfo(extInf.Attributes)
 
 	// #EXTINF: 0 catchup="default" catchup-days="5", Disney Channel
 	// #EXTINF:0 tvg-rec="0",минимакс-воронины HD
-	variables := strings.Split(data, " ")
-	for _, variableRaw := range variables {
-		key, val := util.ParseVariable(variableRaw)
-		if key == "tvg-rec" {
-			days, _ := strconv.ParseInt(val, 10, 32)
-			c.HistoryDays = int(days)
-		}
+	if c.Attributes.Has("tvg-rec") {
+		c.HistoryDays = c.Info.TvgRec
+	} else {
+		c.HistoryDays = c.Info.CatchupDays
 	}
 }
 
 func (c *Channel) GetInfoData(censored bool) string {
+	return c.getInfoData(censored, nil)
+}
+
+// getInfoData formats own attributes with extra ones appended
+func (c *Channel) getInfoData(censored bool, extra *Attributes) string {
 	// #EXTINF: 0 catchup="default" catchup-days="5",Disney Channel
 	// #EXTINF:0 tvg-rec="0",минимакс-воронины HD
 	// censored=1
@@ -82,50 +148,135 @@ func (c *Channel) GetInfoData(censored bool) string {
 	if censored {
 		result += " censored=\"1\""
 	}
+	if extra != nil && extra.Len() != 0 {
+		result += " " + extra.String()
+	}
 	return result + "," + c.Name
 }
 
-func (c *Channel) SetName(nameData string, groupName string) {
+// GetLosslessInfoData keeps all original attributes and merges own overrides on top
+func (c *Channel) GetLosslessInfoData(groupName string, censored bool) string {
+	return c.getLosslessInfoData(groupName, censored, nil)
+}
 
-	content := strings.SplitN(nameData, ",", 2)
+func (c *Channel) getLosslessInfoData(groupName string, censored bool, extra *Attributes) string {
+	attributes := c.Attributes.Clone()
 
-	if content != nil && len(content) > 0 {
-		c.setData(content[0])
-		if len(content) > 1 {
-			c.Name = strings.TrimSpace(content[1])
+	attributes.Set("tvg-rec", strconv.Itoa(c.HistoryDays))
+	if !attributes.Has("catchup") {
+		attributes.Set("catchup", "shift")
+	}
+	attributes.Set("catchup-days", strconv.Itoa(c.HistoryDays))
+	if c.TvgName != "" {
+		attributes.Set("tvg-name", c.TvgName)
+	}
+	if groupName != "" {
+		attributes.Set("group-title", groupName)
+	}
+	if censored {
+		attributes.Set("censored", "1")
+	}
+	if extra != nil {
+		for _, item := range extra.Items() {
+			attributes.Set(item.Key, item.Value)
 		}
 	}
 
+	duration := c.Duration
+	if duration == "" {
+		duration = "0"
+	}
+	return "#EXTINF:" + duration + " " + attributes.String() + "," + c.Name
+}
+
+// SetName parses channel and loads its meta synchronously
+func (c *Channel) SetName(nameData string, groupName string) {
+	if c.parseName(nameData) {
+		c.LoadData(context.Background(), groupName)
+	}
+}
+
+// parseName fills name, attributes and remote id, returns false if remote id is unknown
+func (c *Channel) parseName(nameData string) bool {
+
+	c.infoData = nameData
+
+	extInf := ParseExtInf(nameData)
+	c.setData(extInf)
+	c.Name = extInf.Title
+
 	reg, err := regexp.Compile(`(^([0-9]+))|(\.|\+|-|\s|,|_)`)
 	if err != nil {
 		log.Fatal(err)
 	}
 	c.SortingName = strings.ToLower(reg.ReplaceAllString(c.Name, ""))
 
-	// http://wkejhfk.rossteleccom.net/iptv/ABCD3HG7DW38ZD/205/index.m3u8
-	// host + / + "iptv" + / + key + / + channel_id + / + file
+	if c.RemoteId == "" {
+		channelUrl, err := ParseChannelUrl(c.Url)
+		if err != nil {
+			log.Println("Error in channel url:", err)
+			return false
+		}
+		c.RemoteId = channelUrl.RemoteId
+		c.Provider = channelUrl.Provider
+	} else if c.Provider.Host == "" {
+		u, err := url.Parse(c.Url)
+		if err == nil {
+			c.Provider.Host = u.Hostname()
+		}
+	}
+	return true
+}
+
+// needsProbe true if stored meta is missing, forced to reload, incomplete or stale,
+// NoSampleLoad keeps incomplete and stale meta of known channels
+func (c *Channel) needsProbe(remoteId string, channelData *db.Channel) bool {
+	if channelData == nil || c.ForceReloadData {
+		return true
+	}
+	if c.NoSampleLoad {
+		return false
+	}
+	return !channelData.HasAllMeta() || c.freshness.IsStale(remoteId, channelData.UpdatedAt)
+}
 
-	u, err := url.Parse(c.Url)
-	splittedPath := strings.Split(u.Path, "/")
-	if len(splittedPath) < 3 {
-		log.Println("Error in url path:", splittedPath)
+// LoadData takes channel meta from DB or probes stream, stores changes to DB
+func (c *Channel) LoadData(ctx context.Context, groupName string) {
+	if c.RemoteId == "" {
 		return
 	}
-	remoteId := splittedPath[3]
-	provider := db.Provider{}
-	provider.FromUri(u.Host, splittedPath)
+	remoteId := c.RemoteId
+	provider := c.Provider
 
 	channelData, err := db.QueryGetChannelInfo(remoteId, &provider)
 
-	if channelData == nil || ((!c.NoSampleLoad && !channelData.HasAllMeta()) || c.ForceReloadData) {
-		if c.loadMeta(remoteId) == nil {
-			log.Printf("Failed to load channel meta for remoteId: %s", remoteId)
-		}
-	} else {
+	if channelData != nil {
 		c.Width = channelData.Width
 		c.Height = channelData.Height
 		c.FrameRate = channelData.FrameRate
 		c.TvgName = channelData.TvgName
+		c.setDBDetails(channelData)
+		c.setDBHealth(&channelData.Health)
+	}
+
+	probe := false
+	if c.needsProbe(remoteId, channelData) {
+		probe = c.ForceReloadData || !c.loadCachedMeta(remoteId)
+	}
+	if probe {
+		if c.loadMeta(ctx, remoteId) == nil {
+			if ctx.Err() != nil {
+				// Canceled probe says nothing about channel, keep DB data
+				return
+			}
+			log.Printf("Failed to load channel meta for remoteId: %s", remoteId)
+		}
+	} else if c.healthCheck && !c.NoSampleLoad {
+		// Stored meta says nothing about stream being alive now
+		c.checkHealth(ctx)
+		if ctx.Err() != nil {
+			return
+		}
 	}
 
 	if c.isNeedDBUpdate(channelData) || (channelData != nil && channelData.ChannelName.Group != groupName) {
@@ -135,6 +286,15 @@ func (c *Channel) SetName(nameData string, groupName string) {
 			Width:     c.Width,
 			Height:    c.Height,
 			FrameRate: c.FrameRate,
+			Probed:    c.probed,
+
+			VideoCodec:   c.VideoCodec,
+			VideoProfile: c.Profile,
+			VideoLevel:   c.Level,
+			FieldOrder:   c.FieldOrder,
+			PixFmt:       c.PixFmt,
+			BitRate:      c.BitRate,
+			HasSubtitles: c.HasSubtitles,
 			ChannelName: db.ChannelName{
 				Id:          0,
 				Name:        c.Name,
@@ -143,10 +303,44 @@ func (c *Channel) SetName(nameData string, groupName string) {
 				Provider:    provider,
 			},
 		}
+		for _, track := range c.AudioTracks {
+			dbChannel.AudioCodecs = append(dbChannel.AudioCodecs, track.Codec)
+			dbChannel.AudioChannels = append(dbChannel.AudioChannels, track.Channels)
+			dbChannel.AudioLanguages = append(dbChannel.AudioLanguages, track.Language)
+		}
 		err = db.QueryInsertOrUpdateChannel(dbChannel)
 		if err != nil {
 			log.Println(err)
 		}
+		if channelData == nil || channelData.Id == 0 {
+			channelData = dbChannel
+		}
+	}
+
+	if channelData != nil {
+		c.storeHealth(channelData.Id)
+	}
+}
+
+// setDBDetails takes stream details stored in DB, audio arrays are matched by index
+func (c *Channel) setDBDetails(channelData *db.Channel) {
+	c.VideoCodec = channelData.VideoCodec
+	c.Profile = channelData.VideoProfile
+	c.Level = channelData.VideoLevel
+	c.FieldOrder = channelData.FieldOrder
+	c.PixFmt = channelData.PixFmt
+	c.BitRate = channelData.BitRate
+	c.HasSubtitles = channelData.HasSubtitles
+	c.AudioTracks = nil
+	for i, codec := range channelData.AudioCodecs {
+		track := AudioTrack{Codec: codec}
+		if i < len(channelData.AudioChannels) {
+			track.Channels = channelData.AudioChannels[i]
+		}
+		if i < len(channelData.AudioLanguages) {
+			track.Language = channelData.AudioLanguages[i]
+		}
+		c.AudioTracks = append(c.AudioTracks, track)
 	}
 }
 
@@ -157,6 +351,10 @@ func (c *Channel) isNeedDBUpdate(dbChannel *db.Channel) bool {
 	if dbChannel.Id == 0 {
 		return true
 	}
+	if c.probed {
+		// Refreshes updated_at used by freshness policy
+		return true
+	}
 	if dbChannel.Width != c.Width && c.Width != 0 {
 		return true
 	}
@@ -166,6 +364,12 @@ func (c *Channel) isNeedDBUpdate(dbChannel *db.Channel) bool {
 	if dbChannel.FrameRate != c.FrameRate && c.FrameRate != 0 {
 		return true
 	}
+	if dbChannel.VideoCodec != c.VideoCodec && c.VideoCodec != "" {
+		return true
+	}
+	if dbChannel.BitRate != c.BitRate && c.BitRate != 0 {
+		return true
+	}
 	if dbChannel.ChannelName.Id == 0 {
 		return true
 	}
@@ -181,29 +385,67 @@ func (c *Channel) isNeedDBUpdate(dbChannel *db.Channel) bool {
 	return false
 }
 
-func (c *Channel) loadMeta(remoteId string) *ffprobe.MetaData {
+func (c *Channel) loadMeta(ctx context.Context, remoteId string) *ffprobe.MetaData {
 	if c.Url == "" {
 		return nil
 	}
 
-	media := ReadUrl(c.Url, c.ForceReloadData, c.NoSampleLoad)
+	prober := c.prober
+	if prober == nil {
+		prober = DefaultProber()
+	}
+	metaData, err := prober.Probe(ctx, remoteId, c.Url)
+	if ctx.Err() != nil {
+		return nil
+	}
+	if err != nil {
+		log.Printf("Failed to probe %s: %v", c.Url, err)
+		c.setProbeResult(err)
+		return nil
+	}
+	metaData = c.applyMeta(metaData)
+	if metaData == nil {
+		c.setProbeResult(ErrNoVideo)
+	} else {
+		c.setProbeResult(nil)
+		c.probed = true
+		c.metaCache.Put(remoteId, c.Url, metaData)
+	}
+	return metaData
+}
+
+// loadCachedMeta applies fresh meta from cache file, returns false if there is none
+func (c *Channel) loadCachedMeta(remoteId string) bool {
+	metaData, probedAt, ok := c.metaCache.Get(remoteId)
+	if !ok || c.freshness.IsStale(remoteId, probedAt) {
+		return false
+	}
+	return c.applyMeta(metaData) != nil
+}
 
-	if media != nil && len(media.Records) > 0 {
+// applyMeta takes dimensions and stream details from meta, returns nil if there is no valid video stream
+func (c *Channel) applyMeta(metaData *ffprobe.MetaData) *ffprobe.MetaData {
+	if metaData == nil {
+		return nil
+	}
+	vidStream := metaData.GetVideoStream()
+	if vidStream == nil || vidStream.Width == 0 || vidStream.Height == 0 {
+		return nil
+	}
+	c.Width = vidStream.Width
+	c.Height = vidStream.Height
+	c.FrameRate = vidStream.RFrameRate.RoundedQuotient()
 
-		var metaData *ffprobe.MetaData
-		for i := len(media.Records) - 1; i >= 0; i-- {
-			metaData = ffprobe.LoadMetaData(remoteId, media.Records[i].Url)
-			if metaData != nil {
-				vidStream := metaData.GetVideoStream()
-				if vidStream != nil && vidStream.Width != 0 && vidStream.Height != 0 {
-					c.Width = vidStream.Width
-					c.Height = vidStream.Height
-					c.FrameRate = vidStream.RFrameRate.RoundedQuotient()
-					return metaData
-				}
-			}
-		}
-		return metaData
+	c.VideoCodec = vidStream.CodecName
+	c.Profile = vidStream.Profile
+	c.Level = vidStream.Level
+	c.FieldOrder = vidStream.FieldOrder
+	c.PixFmt = vidStream.PixFmt
+	c.BitRate = metaData.GetBitRate()
+	c.HasSubtitles = metaData.HasSubtitles()
+	c.AudioTracks = nil
+	for _, stream := range metaData.GetAudioStreams() {
+		c.AudioTracks = append(c.AudioTracks, AudioTrack{Codec: stream.CodecName, Channels: stream.Channels, Language: stream.Language()})
 	}
-	return nil
+	return metaData
 }
@@ -0,0 +1,210 @@
+package meta
+
+import (
+	"m3u8/cfg"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// BackupUrlSeparator joins backup urls in backup attribute value
+const BackupUrlSeparator = "|"
+
+// betterThan ranks channels of one cluster: fewer failures first, then resolution and frame rate
+func (c *Channel) betterThan(other *Channel) bool {
+	if c.Health.Failures != other.Health.Failures {
+		return c.Health.Failures < other.Health.Failures
+	}
+	if c.Width*c.Height != other.Width*other.Height {
+		return c.Width*c.Height > other.Width*other.Height
+	}
+	return c.FrameRate > other.FrameRate
+}
+
+// promoteBackup copy of first backup passing accept with rest of cluster as its backups, nil if there is none
+func promoteBackup(channel *Channel, accept func(*Channel) bool) *Channel {
+	for i, backup := range channel.Backups {
+		if !accept(backup) {
+			continue
+		}
+		promoted := *backup
+		promoted.Backups = make([]*Channel, 0, len(channel.Backups))
+		promoted.Backups = append(promoted.Backups, channel)
+		promoted.Backups = append(promoted.Backups, channel.Backups[:i]...)
+		promoted.Backups = append(promoted.Backups, channel.Backups[i+1:]...)
+		return &promoted
+	}
+	return nil
+}
+
+// remoteKey remote id is unique within provider and source list only, url host stands for unknown provider
+func remoteKey(c *Channel) string {
+	if c.RemoteId == "" {
+		return ""
+	}
+	host := c.Provider.Host
+	if host == "" {
+		if u, err := url.Parse(c.Url); err == nil {
+			host = u.Hostname()
+		}
+	}
+	return c.Source + "\x00" + host + "\x00" + c.RemoteId
+}
+
+// rank orders duplicates by source priority first for priority select rule, by stream quality otherwise
+func (m *Media) rank(c *Channel, other *Channel) bool {
+	if m.selectRule == cfg.SelectPriority && c.SourcePriority != other.SourcePriority {
+		return c.SourcePriority > other.SourcePriority
+	}
+	if c.betterThan(other) || other.betterThan(c) {
+		return c.betterThan(other)
+	}
+	return c.SourcePriority > other.SourcePriority
+}
+
+// Deduplicate clusters channels by normalized name or remote id of same provider and source, best ranked channel of cluster
+// stays in its group as primary, others are removed from groups and kept as its Backups
+func (m *Media) Deduplicate(mode string, backupAttribute string) {
+	if mode != cfg.DedupModeDrop && mode != cfg.DedupModeSeparate && mode != cfg.DedupModeAttribute {
+		return
+	}
+	m.dedupMode = mode
+	m.backupAttribute = backupAttribute
+
+	var channels []*Channel
+	for _, group := range m.Groups {
+		for _, channel := range group.Channels {
+			if channel != nil {
+				channels = append(channels, channel)
+			}
+		}
+	}
+
+	parent := make([]int, len(channels))
+	var find func(i int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	union := func(keys map[string]int, key string, i int) {
+		if key == "" {
+			return
+		}
+		if j, ok := keys[key]; ok {
+			parent[find(i)] = find(j)
+			return
+		}
+		keys[key] = i
+	}
+
+	names := map[string]int{}
+	remoteIds := map[string]int{}
+	for i, channel := range channels {
+		parent[i] = i
+		union(names, NormalizeName(channel.Name), i)
+		union(remoteIds, remoteKey(channel), i)
+	}
+
+	clusters := map[int][]*Channel{}
+	for i, channel := range channels {
+		root := find(i)
+		clusters[root] = append(clusters[root], channel)
+	}
+
+	backups := map[*Channel]bool{}
+	for _, cluster := range clusters {
+		if len(cluster) < 2 {
+			continue
+		}
+		// Backups of earlier deduplication are ranked again with the cluster
+		seen := map[*Channel]bool{}
+		for _, channel := range cluster {
+			seen[channel] = true
+		}
+		for _, channel := range cluster {
+			for _, backup := range channel.Backups {
+				if !seen[backup] {
+					seen[backup] = true
+					cluster = append(cluster, backup)
+				}
+			}
+			channel.Backups = nil
+		}
+		sort.SliceStable(cluster, func(i, j int) bool {
+			return m.rank(cluster[i], cluster[j])
+		})
+		primary := cluster[0]
+		primary.Backups = cluster[1:]
+		for _, backup := range cluster[1:] {
+			backups[backup] = true
+		}
+	}
+	if len(backups) == 0 {
+		return
+	}
+
+	for _, group := range m.Groups {
+		kept := group.Channels[:0]
+		for _, channel := range group.Channels {
+			if !backups[channel] {
+				kept = append(kept, channel)
+			}
+		}
+		group.Channels = kept
+	}
+}
+
+// writtenBackups backups emitted for channel by dedup mode, backups filtered out by output view are skipped
+// as well as dead ones if output handles dead channels
+func (m *Media) writtenBackups(view *outputView, channel *Channel) []*Channel {
+	if m.dedupMode != cfg.DedupModeSeparate && m.dedupMode != cfg.DedupModeAttribute {
+		return nil
+	}
+	output := view.output
+	var backups []*Channel
+	for _, backup := range channel.Backups {
+		backup, ok := view.channel(backup)
+		if !ok {
+			continue
+		}
+		if isDeadOutput(output) && backup.IsDead(output.DeadFailures) {
+			continue
+		}
+		backups = append(backups, backup)
+	}
+	return backups
+}
+
+func backupUrls(backups []*Channel) string {
+	urls := make([]string, 0, len(backups))
+	for _, backup := range backups {
+		urls = append(urls, backup.Url)
+	}
+	return strings.Join(urls, BackupUrlSeparator)
+}
+
+// Merge joins medias of several lists into one, groups of same name are combined and
+// channels matched across lists are deduplicated with select rule, dedup mode "off" drops duplicates.
+// Channels are shared with source medias and get their Backups replaced.
+func Merge(medias []*Media, selectRule string, mode string, backupAttribute string) *Media {
+	merged := newMedia(nil)
+	merged.validFileType = true
+	merged.selectRule = selectRule
+	for _, media := range medias {
+		if media == nil {
+			continue
+		}
+		for _, group := range media.Groups {
+			target := merged.CreateGroup(group.Name)
+			target.Channels = append(target.Channels, group.Channels...)
+		}
+	}
+
+	if mode != cfg.DedupModeSeparate && mode != cfg.DedupModeAttribute {
+		mode = cfg.DedupModeDrop
+	}
+	merged.Deduplicate(mode, backupAttribute)
+	return merged
+}
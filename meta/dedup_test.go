@@ -0,0 +1,176 @@
+package meta
+
+import (
+	"bytes"
+	"m3u8/cfg"
+	"m3u8/db"
+	"strings"
+	"testing"
+)
+
+func newDedupMedia() (*Media, []*Channel) {
+	channels := []*Channel{
+		{Name: "Кино HD", SortingName: "кинохd", RemoteId: "1", Url: "http://a/1", Width: 1280, Height: 720, FrameRate: 50},
+		{Name: "Кино HD", SortingName: "кинохd", RemoteId: "2", Url: "http://b/2", Width: 1920, Height: 1080, FrameRate: 25},
+		{Name: "Кино HD", SortingName: "кинохd", RemoteId: "3", Url: "http://c/3", Width: 1920, Height: 1080, FrameRate: 50,
+			Health: ChannelHealth{Status: HealthTimeout, Failures: 1}},
+		{Name: "Спорт", SortingName: "спорт", RemoteId: "4", Url: "http://a/4", Provider: db.Provider{Host: "a"}},
+		{Name: "Sport", SortingName: "sport", RemoteId: "4", Url: "http://b/4", Provider: db.Provider{Host: "a"}},
+		{Name: "Новости", SortingName: "новости", RemoteId: "5", Url: "http://a/5"},
+	}
+	media := &Media{Groups: []*Group{
+		{Name: "кино", Channels: []*Channel{channels[0], channels[3], channels[5]}},
+		{Name: "HD", Channels: []*Channel{channels[1], channels[2], channels[4]}},
+	}}
+	return media, channels
+}
+
+func TestDeduplicate(t *testing.T) {
+	media, channels := newDedupMedia()
+	media.Deduplicate(cfg.DedupModeDrop, "")
+
+	if len(media.Groups[0].Channels) != 2 || media.Groups[0].Channels[0] != channels[3] || media.Groups[0].Channels[1] != channels[5] {
+		t.Fatalf("unexpected first group: %+v", media.Groups[0].Channels)
+	}
+	if len(media.Groups[1].Channels) != 1 || media.Groups[1].Channels[0] != channels[1] {
+		t.Fatalf("1080p healthy channel must be primary: %+v", media.Groups[1].Channels)
+	}
+	// Failing channel ranks below lower resolution healthy one
+	backups := channels[1].Backups
+	if len(backups) != 2 || backups[0] != channels[0] || backups[1] != channels[2] {
+		t.Fatalf("unexpected backups: %+v", backups)
+	}
+	if len(channels[3].Backups) != 1 || channels[3].Backups[0] != channels[4] {
+		t.Fatalf("channels of same remote id must be merged: %+v", channels[3].Backups)
+	}
+
+	media, _ = newDedupMedia()
+	media.Deduplicate(cfg.DedupModeOff, "")
+	if len(media.Groups[0].Channels) != 3 || len(media.Groups[1].Channels) != 3 {
+		t.Fatalf("disabled dedup must keep all channels")
+	}
+}
+
+func TestWriteBackups(t *testing.T) {
+	urls := func(output string) string {
+		var result []string
+		for _, line := range strings.Split(output, "\n") {
+			if strings.HasPrefix(line, "http") {
+				result = append(result, line)
+			}
+		}
+		return strings.Join(result, ",")
+	}
+
+	media, _ := newDedupMedia()
+	media.Deduplicate(cfg.DedupModeSeparate, "")
+	var buf bytes.Buffer
+	if err := media.write(&buf, &cfg.Output{}, ""); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	if result := urls(buf.String()); result != "http://a/4,http://b/4,http://a/5,http://b/2,http://a/1,http://c/3" {
+		t.Fatalf("unexpected separate output: %s", result)
+	}
+
+	media, _ = newDedupMedia()
+	media.Deduplicate(cfg.DedupModeAttribute, "backup-url")
+	buf.Reset()
+	if err := media.write(&buf, &cfg.Output{DeadMode: cfg.DeadModeExclude, DeadFailures: 1}, ""); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	if result := urls(buf.String()); result != "http://a/4,http://a/5,http://b/2" {
+		t.Fatalf("unexpected attribute output: %s", result)
+	}
+	if !strings.Contains(buf.String(), `backup-url="http://a/1",Кино HD`) || !strings.Contains(buf.String(), `backup-url="http://b/4",Спорт`) {
+		t.Fatalf("backup attribute is missing:\n%s", buf.String())
+	}
+}
+
+func TestMerge(t *testing.T) {
+	read := func(source string, priority int, url string) *Media {
+		playlist := "#EXTM3U\n#EXTINF:0 group-title=\"Кино\",Кино HD\n" + url + "\n"
+		media, _, err := Read(strings.NewReader(playlist), &ReadOptions{Source: source, SourcePriority: priority})
+		if err != nil {
+			t.Fatalf("Read failed: %v", err)
+		}
+		return media
+	}
+
+	main := read("main", 10, "http://main/iptv/key/1/index.m3u8")
+	backup := read("backup", 1, "http://backup/iptv/key/2/index.m3u8")
+	main.Groups[0].Channels[0].Width, main.Groups[0].Channels[0].Height = 1280, 720
+	backup.Groups[0].Channels[0].Width, backup.Groups[0].Channels[0].Height = 1920, 1080
+
+	merged := Merge([]*Media{main, backup}, cfg.SelectPriority, cfg.DedupModeOff, "")
+	if len(merged.Groups) != 1 || len(merged.Groups[0].Channels) != 1 {
+		t.Fatalf("unexpected merged groups: %+v", merged.Groups)
+	}
+	primary := merged.Groups[0].Channels[0]
+	if primary.Source != "main" || len(primary.Backups) != 1 || primary.Backups[0].Source != "backup" {
+		t.Fatalf("priority select must keep main source: %+v", primary)
+	}
+	if len(main.Groups[0].Channels) != 1 || len(backup.Groups[0].Channels) != 1 {
+		t.Fatalf("source medias must keep their groups")
+	}
+
+	merged = Merge([]*Media{main, backup}, cfg.SelectQuality, cfg.DedupModeOff, "")
+	if primary = merged.Groups[0].Channels[0]; primary.Source != "backup" || primary.Width != 1920 || len(primary.Backups) != 1 {
+		t.Fatalf("quality select must keep 1080p source: %+v", primary)
+	}
+}
+
+func TestRemoteIdCollision(t *testing.T) {
+	read := func(source string, playlist string) *Media {
+		media, _, err := Read(strings.NewReader("#EXTM3U\n"+playlist), &ReadOptions{Source: source})
+		if err != nil {
+			t.Fatalf("Read failed: %v", err)
+		}
+		return media
+	}
+	first := read("first", "#EXTINF:0 group-title=\"Кино\",Кино HD\nhttp://tv.first.net/iptv/key/101/index.m3u8\n")
+	second := read("second", "#EXTINF:0 group-title=\"Спорт\",Матч ТВ\nhttp://tv.second.net/iptv/key/101/index.m3u8\n")
+
+	merged := Merge([]*Media{first, second}, cfg.SelectQuality, cfg.DedupModeAttribute, "backup-url")
+	if len(merged.Groups) != 2 || len(merged.Groups[0].Channels) != 1 || len(merged.Groups[1].Channels) != 1 {
+		t.Fatalf("same remote id of different providers must not be merged: %+v", merged.Groups)
+	}
+
+	// Single list mixing providers
+	mixed := read("mixed", "#EXTINF:0 group-title=\"Кино\",Кино HD\nhttp://tv.first.net/iptv/key/101/index.m3u8\n"+
+		"#EXTINF:0 group-title=\"Спорт\",Матч ТВ\nhttp://tv.second.net/iptv/key/101/index.m3u8\n")
+	mixed.Deduplicate(cfg.DedupModeAttribute, "backup-url")
+	if len(mixed.Groups) != 2 || len(mixed.Groups[0].Channels) != 1 || len(mixed.Groups[1].Channels) != 1 {
+		t.Fatalf("same remote id of different hosts must not be merged: %+v", mixed.Groups)
+	}
+
+	// Same provider in two sources is not merged by id either
+	mirror := read("mirror", "#EXTINF:0 group-title=\"Спорт\",Матч ТВ\nhttp://tv.first.net/iptv/key/101/index.m3u8\n")
+	merged = Merge([]*Media{first, mirror}, cfg.SelectQuality, cfg.DedupModeAttribute, "backup-url")
+	if len(merged.Groups) != 2 {
+		t.Fatalf("remote id must not be merged across sources: %+v", merged.Groups)
+	}
+}
+
+func TestDeadPrimary(t *testing.T) {
+	dead := &Channel{Name: "Кино HD", RemoteId: "1", Url: "http://a/1", Width: 1920, Height: 1080, Health: ChannelHealth{Status: HealthTimeout, Failures: 3}}
+	live := &Channel{Name: "Кино HD", RemoteId: "2", Url: "http://b/2", Width: 1280, Height: 720, Health: ChannelHealth{Status: HealthTimeout, Failures: 1}}
+	media := &Media{Groups: []*Group{{Name: "кино", Channels: []*Channel{dead, live}}}}
+	media.Deduplicate(cfg.DedupModeAttribute, "backup-url")
+	if len(media.Groups[0].Channels) != 1 || media.Groups[0].Channels[0] != live {
+		t.Fatalf("channel with fewer failures must be primary: %+v", media.Groups[0].Channels)
+	}
+
+	// Primary kept by source priority gives way to live backup in outputs excluding dead channels
+	dead.Backups, live.Backups = []*Channel{live}, nil
+	media.Groups[0].Channels = []*Channel{dead}
+	var buf bytes.Buffer
+	if err := media.write(&buf, &cfg.Output{DeadMode: cfg.DeadModeExclude, DeadFailures: 3}, ""); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	if !strings.Contains(buf.String(), "http://b/2") || strings.Contains(buf.String(), "http://a/1") {
+		t.Fatalf("live backup must replace dead primary:\n%s", buf.String())
+	}
+	if len(dead.Backups) != 1 || dead.Backups[0] != live {
+		t.Fatalf("promotion must not change media")
+	}
+}
@@ -0,0 +1,127 @@
+package meta
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/encoding/unicode"
+	"io"
+	"mime"
+	"strings"
+	"unicode/utf8"
+)
+
+// FallbackEncoding used for playlists which are not valid UTF-8 and have no charset hints
+var FallbackEncoding encoding.Encoding = charmap.Windows1251
+
+var (
+	bomUTF8    = []byte{0xEF, 0xBB, 0xBF}
+	bomUTF16LE = []byte{0xFF, 0xFE}
+	bomUTF16BE = []byte{0xFE, 0xFF}
+)
+
+// charsetFromContentType extracts charset parameter: "audio/x-mpegurl; charset=windows-1251"
+func charsetFromContentType(contentType string) string {
+	if contentType == "" {
+		return ""
+	}
+	_, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return ""
+	}
+	return params["charset"]
+}
+
+func lookupEncoding(name string) (encoding.Encoding, error) {
+	name = strings.TrimSpace(strings.ToLower(name))
+	switch name {
+	case "cp1251", "win1251", "windows1251":
+		return charmap.Windows1251, nil
+	}
+	enc, err := htmlindex.Get(name)
+	if err != nil {
+		return nil, fmt.Errorf("unknown encoding %s", name)
+	}
+	return enc, nil
+}
+
+func looksLikePlaylist(head []byte) bool {
+	if bytes.HasPrefix(head, bomUTF16LE) || bytes.HasPrefix(head, bomUTF16BE) {
+		return true
+	}
+	head = bytes.TrimPrefix(head, bomUTF8)
+	return bytes.HasPrefix(bytes.TrimLeft(head, " \t\r\n"), []byte("#EXTM3U"))
+}
+
+// decodeToUTF8 strips BOM and transcodes data to UTF-8.
+// Precedence: forced encoding, BOM, Content-Type charset, UTF-8 validation with fallback encoding
+func decodeToUTF8(r io.Reader, forceEncoding string, contentType string) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	head, _ := br.Peek(512)
+	if !looksLikePlaylist(head) {
+		// Stream urls return endless binary data, header check fails on first line anyway
+		return br, nil
+	}
+
+	data, err := io.ReadAll(br)
+	if err != nil {
+		return nil, err
+	}
+
+	var enc encoding.Encoding
+
+	if forceEncoding != "" {
+		enc, err = lookupEncoding(forceEncoding)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	switch {
+	case bytes.HasPrefix(data, bomUTF8):
+		data = data[len(bomUTF8):]
+		if enc == nil {
+			enc = unicode.UTF8
+		}
+	case bytes.HasPrefix(data, bomUTF16LE):
+		if enc == nil {
+			data = data[len(bomUTF16LE):]
+			enc = unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM)
+		}
+	case bytes.HasPrefix(data, bomUTF16BE):
+		if enc == nil {
+			data = data[len(bomUTF16BE):]
+			enc = unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM)
+		}
+	}
+
+	if enc == nil {
+		if charset := charsetFromContentType(contentType); charset != "" {
+			enc, err = lookupEncoding(charset)
+			if err != nil {
+				// Providers send garbage charsets, content detection is more reliable then
+				enc = nil
+			}
+		}
+	}
+
+	if enc == nil {
+		if utf8.Valid(data) {
+			return bytes.NewReader(data), nil
+		}
+		enc = FallbackEncoding
+	}
+
+	if enc == unicode.UTF8 || enc == encoding.Nop {
+		return bytes.NewReader(data), nil
+	}
+
+	decoded, err := enc.NewDecoder().Bytes(data)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(decoded), nil
+}
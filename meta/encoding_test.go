@@ -0,0 +1,60 @@
+package meta
+
+import (
+	"bytes"
+	"golang.org/x/text/encoding/charmap"
+	"io"
+	"testing"
+)
+
+func TestDecodeToUTF8(t *testing.T) {
+	source := "#EXTM3U\n#EXTINF:0,Первый HD\n#EXTGRP:кино\nhttp://host/1\n"
+	cp1251, err := charmap.Windows1251.NewEncoder().String(source)
+	if err != nil {
+		t.Fatalf("failed to encode source: %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		data        []byte
+		encoding    string
+		contentType string
+	}{
+		{name: "utf8", data: []byte(source)},
+		{name: "utf8 bom", data: append([]byte{0xEF, 0xBB, 0xBF}, source...)},
+		{name: "cp1251 detected", data: []byte(cp1251)},
+		{name: "cp1251 content type", data: []byte(cp1251), contentType: "audio/x-mpegurl; charset=windows-1251"},
+		{name: "cp1251 forced", data: []byte(cp1251), encoding: "cp1251", contentType: "audio/x-mpegurl; charset=utf-8"},
+	}
+
+	for _, test := range tests {
+		media, err := readRecords(bytes.NewReader(test.data), &ReadOptions{Encoding: test.encoding, ContentType: test.contentType})
+		if err != nil {
+			t.Fatalf("%s: readRecords failed: %v", test.name, err)
+		}
+		if len(media.Records) != 1 || media.Records[0].NameData != "0,Первый HD" || media.Records[0].GroupName != "кино" {
+			t.Fatalf("%s: unexpected records: %+v", test.name, media.Records)
+		}
+	}
+}
+
+// endlessReader stream url body which is never finished
+type endlessReader struct{}
+
+func (endlessReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 0x47
+	}
+	return len(p), nil
+}
+
+func TestDecodeStreamBody(t *testing.T) {
+	r, err := decodeToUTF8(endlessReader{}, "", "")
+	if err != nil {
+		t.Fatalf("decodeToUTF8 failed: %v", err)
+	}
+	head := make([]byte, 4)
+	if _, err = io.ReadFull(r, head); err != nil || !bytes.Equal(head, []byte{0x47, 0x47, 0x47, 0x47}) {
+		t.Fatalf("stream body must be passed through: %v %v", head, err)
+	}
+}
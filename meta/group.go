@@ -5,7 +5,6 @@ import (
 	"m3u8/cfg"
 	"m3u8/util"
 	"sort"
-	"strings"
 )
 
 type Group struct {
@@ -13,13 +12,13 @@ type Group struct {
 	Channels []*Channel
 }
 
+// FindChannel first channel matching name by NormalizeName or CanonicalName if there is no normalized match
 func (g *Group) FindChannel(channelName string) (*Channel, int) {
-	for i2, channel := range g.Channels {
-		if channel != nil && strings.ToLower(channel.Name) == strings.ToLower(channelName) {
-			return channel, i2
-		}
+	indexes := matchChannels(g.Channels, channelName)
+	if len(indexes) == 0 {
+		return nil, -1
 	}
-	return nil, -1
+	return g.Channels[indexes[0]], indexes[0]
 }
 
 func (g *Group) mergeChannels(group *Group) {
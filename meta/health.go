@@ -0,0 +1,145 @@
+package meta
+
+import (
+	"context"
+	"errors"
+	log "github.com/sirupsen/logrus"
+	"io"
+	"m3u8/db"
+	"m3u8/tsprobe"
+	"m3u8/util"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Probe outcome statuses stored in channel health
+const (
+	HealthOk          = db.HealthStatusOk
+	HealthTimeout     = "timeout"
+	HealthClientError = "http_4xx"
+	HealthServerError = "http_5xx"
+	HealthNoVideo     = "no_video"
+	HealthError       = "error"
+)
+
+// ChannelHealth last probe outcome, Failures counts consecutive failed probes
+type ChannelHealth struct {
+	Status    string
+	Failures  int
+	CheckedAt time.Time
+
+	// checked health was updated by probe of this run
+	checked bool
+	message string
+}
+
+// ProbeStatus classifies probe error
+func ProbeStatus(err error) string {
+	if err == nil {
+		return HealthOk
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return HealthTimeout
+	}
+	var statusErr *util.HTTPStatusError
+	if errors.As(err, &statusErr) {
+		if statusErr.StatusCode >= 500 {
+			return HealthServerError
+		}
+		return HealthClientError
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return HealthTimeout
+	}
+	if errors.Is(err, ErrNoVideo) || errors.Is(err, tsprobe.ErrNoProgram) {
+		return HealthNoVideo
+	}
+	return HealthError
+}
+
+// HealthChecker cheap stream availability check, probers without it are checked by plain stream request
+type HealthChecker interface {
+	Check(ctx context.Context, streamUrl string) error
+}
+
+// checkStream requests stream and reads its first bytes without parsing media
+func checkStream(ctx context.Context, streamUrl string) error {
+	resp, err := util.MakeHTTPRequestContext(ctx, http.MethodGet, streamUrl, nil, nil, nil, 10)
+	if resp != nil && resp.Body != nil {
+		defer resp.Body.Close()
+	}
+	if err != nil {
+		return err
+	}
+	if err = util.CheckHTTPStatus(resp); err != nil {
+		return err
+	}
+	_, err = io.ReadAtLeast(resp.Body, make([]byte, 188), 1)
+	return err
+}
+
+// checkHealth records availability of channel which meta is not probed by this run,
+// non http(s) streams are checked by prober only
+func (c *Channel) checkHealth(ctx context.Context) {
+	if c.Url == "" {
+		return
+	}
+	var err error
+	if checker, ok := c.prober.(HealthChecker); ok {
+		err = checker.Check(ctx, c.Url)
+	} else if u, parseErr := url.Parse(c.Url); parseErr == nil && (u.Scheme == "http" || u.Scheme == "https") {
+		err = checkStream(ctx, c.Url)
+	} else {
+		return
+	}
+	if ctx.Err() != nil {
+		return
+	}
+	if err != nil {
+		log.Printf("Health check of %s failed: %v", c.Url, err)
+	}
+	c.setProbeResult(err)
+}
+
+// IsDead true if last failures probes of channel failed in a row, zero failures disables check
+func (c *Channel) IsDead(failures int) bool {
+	return failures > 0 && c.Health.Status != HealthOk && c.Health.Failures >= failures
+}
+
+// setProbeResult counts probe outcome in memory, DB counter replaces it once stored
+func (c *Channel) setProbeResult(err error) {
+	c.Health.Status = ProbeStatus(err)
+	c.Health.CheckedAt = time.Now()
+	c.Health.checked = true
+	c.Health.message = ""
+	if err != nil {
+		c.Health.message = err.Error()
+	}
+	if c.Health.Status == HealthOk {
+		c.Health.Failures = 0
+	} else {
+		c.Health.Failures++
+	}
+}
+
+func (c *Channel) setDBHealth(health *db.ChannelHealth) {
+	c.Health.Status = health.Status
+	c.Health.Failures = health.ConsecutiveFailures
+	c.Health.CheckedAt = health.CheckedAt
+}
+
+// storeHealth adds probe outcome of this run to DB
+func (c *Channel) storeHealth(channelId int64) {
+	if !c.Health.checked || channelId == 0 {
+		return
+	}
+	health, err := db.QueryAddProbeResult(channelId, c.Health.Status, c.Health.message)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	c.setDBHealth(health)
+}
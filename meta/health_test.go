@@ -0,0 +1,99 @@
+package meta
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"m3u8/cfg"
+	"m3u8/util"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestProbeStatus(t *testing.T) {
+	cases := map[error]string{
+		nil:                         HealthOk,
+		context.DeadlineExceeded:    HealthTimeout,
+		ErrNoVideo:                  HealthNoVideo,
+		errors.New("exit status 1"): HealthError,
+		fmt.Errorf("ffprobe failed: %w", &util.HTTPStatusError{StatusCode: 404}): HealthClientError,
+		fmt.Errorf("ffprobe failed: %w", &util.HTTPStatusError{StatusCode: 502}): HealthServerError,
+	}
+	for err, expected := range cases {
+		if status := ProbeStatus(err); status != expected {
+			t.Fatalf("unexpected status of %v: %s, expected %s", err, status, expected)
+		}
+	}
+}
+
+func TestDeadChannels(t *testing.T) {
+	streamUrl := "http://host/iptv/key/101/index.m3u8"
+	fake := NewFakeProber().Fail(streamUrl, &util.HTTPStatusError{StatusCode: 404})
+	dead := &Channel{Url: streamUrl, prober: fake, Name: "Dead"}
+	for i := 0; i < 3; i++ {
+		dead.loadMeta(context.Background(), "101")
+	}
+	if dead.Health.Status != HealthClientError || dead.Health.Failures != 3 || !dead.IsDead(3) || dead.IsDead(4) {
+		t.Fatalf("unexpected health: %+v", dead.Health)
+	}
+
+	fake.Add(streamUrl, FakeMetaData(1280, 720, 25))
+	alive := &Channel{Url: streamUrl, prober: fake, Name: "Alive", Health: dead.Health}
+	alive.loadMeta(context.Background(), "101")
+	if alive.Health.Status != HealthOk || alive.Health.Failures != 0 || alive.IsDead(1) {
+		t.Fatalf("successful probe must reset failures: %+v", alive.Health)
+	}
+
+	media := &Media{Groups: []*Group{{Name: "кино", Channels: []*Channel{dead, alive}}}}
+	outputs := map[string]string{
+		cfg.DeadModeKeep:    "Dead,#EXTGRP:кино,Alive,#EXTGRP:кино",
+		cfg.DeadModeExclude: "Alive,#EXTGRP:кино",
+		cfg.DeadModeMove:    "Alive,#EXTGRP:кино,Dead,#EXTGRP:dead",
+	}
+	for mode, expected := range outputs {
+		var buf bytes.Buffer
+		err := media.write(&buf, &cfg.Output{DeadMode: mode, DeadFailures: 3, DeadGroup: "dead"}, "")
+		if err != nil {
+			t.Fatalf("write failed: %v", err)
+		}
+		var lines []string
+		for _, line := range strings.Split(buf.String(), "\n") {
+			if strings.HasPrefix(line, "#EXTGRP:") {
+				lines = append(lines, line)
+			} else if strings.HasPrefix(line, "#EXTINF:") {
+				lines = append(lines, line[strings.LastIndex(line, ",")+1:])
+			}
+		}
+		if result := strings.Join(lines, ","); result != expected {
+			t.Fatalf("unexpected %s output: %s", mode, result)
+		}
+	}
+}
+
+func TestHealthCheckOfCachedMeta(t *testing.T) {
+	streamUrl := "http://host/iptv/key/101/index.m3u8"
+	cache, err := OpenMetaCache(filepath.Join(t.TempDir(), "meta_cache.json"))
+	if err != nil {
+		t.Fatalf("OpenMetaCache failed: %v", err)
+	}
+	cache.Put("101", streamUrl, FakeMetaData(1920, 1080, 25))
+
+	fake := NewFakeProber().Fail(streamUrl, &util.HTTPStatusError{StatusCode: 404})
+	channel := &Channel{Url: streamUrl, prober: fake, metaCache: cache, healthCheck: true}
+	channel.SetName(`0 tvg-rec="3",Kino HD`, "кино")
+	if len(fake.Calls()) != 0 || len(fake.Checks()) != 1 || channel.Width != 1920 {
+		t.Fatalf("fresh meta must be health checked without probing: %v, %v", fake.Calls(), fake.Checks())
+	}
+	if channel.Health.Status != HealthClientError || channel.Health.Failures != 1 {
+		t.Fatalf("failed check must count as failure: %+v", channel.Health)
+	}
+
+	// Plain stream request speaks http only
+	multicast := &Channel{Url: "udp://@239.0.0.1:1234", prober: &ManifestProber{}}
+	multicast.checkHealth(context.Background())
+	if multicast.Health.checked || multicast.Health.Status != "" {
+		t.Fatalf("non http stream must not be checked by plain request: %+v", multicast.Health)
+	}
+}
@@ -0,0 +1,328 @@
+package meta
+
+import (
+	"m3u8/ffprobe"
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Variant stream from master playlist
+// #EXT-X-STREAM-INF:BANDWIDTH=2149280,RESOLUTION=1280x720,FRAME-RATE=25.000,CODECS="avc1.64001f,mp4a.40.2"
+// #EXT-X-I-FRAME-STREAM-INF:BANDWIDTH=86000,URI="iframe.m3u8"
+type Variant struct {
+	Bandwidth        int
+	AverageBandwidth int
+	Width            int
+	Height           int
+	FrameRate        float64
+	Codecs           string
+
+	// Rendition group ids
+	Audio          string
+	Video          string
+	Subtitles      string
+	ClosedCaptions string
+
+	IFrame bool
+	Uri    string
+
+	Attributes *Attributes
+}
+
+func newVariant(attributes *Attributes, iFrame bool) *Variant {
+	v := &Variant{
+		Bandwidth:        attributes.Int("BANDWIDTH", 0),
+		AverageBandwidth: attributes.Int("AVERAGE-BANDWIDTH", 0),
+		Codecs:           attributes.Value("CODECS"),
+		Audio:            attributes.Value("AUDIO"),
+		Video:            attributes.Value("VIDEO"),
+		Subtitles:        attributes.Value("SUBTITLES"),
+		ClosedCaptions:   attributes.Value("CLOSED-CAPTIONS"),
+		IFrame:           iFrame,
+		Attributes:       attributes,
+	}
+	if iFrame {
+		v.Uri = attributes.Value("URI")
+	}
+
+	resolution := strings.Split(attributes.Value("RESOLUTION"), "x")
+	if len(resolution) == 2 {
+		v.Width, _ = strconv.Atoi(resolution[0])
+		v.Height, _ = strconv.Atoi(resolution[1])
+	}
+	v.FrameRate, _ = strconv.ParseFloat(attributes.Value("FRAME-RATE"), 64)
+
+	return v
+}
+
+// HasAllMeta variant has enough data to skip stream probing
+func (v *Variant) HasAllMeta() bool {
+	return v.Width != 0 && v.Height != 0 && v.FrameRate != 0
+}
+
+// codecNames maps RFC 6381 CODECS entries to ffprobe codec names
+var codecNames = map[string]string{
+	"avc1": "h264",
+	"avc3": "h264",
+	"hvc1": "hevc",
+	"hev1": "hevc",
+	"mp4a": "aac",
+	"ac-3": "ac3",
+	"ec-3": "eac3",
+}
+
+// MetaData video stream description built from manifest data, audio codecs are taken from CODECS
+func (v *Variant) MetaData() *ffprobe.MetaData {
+	frameRate := ffprobe.NewFraction(int(math.Round(v.FrameRate*1000)), 1000)
+	video := ffprobe.StreamData{
+		CodecType:    "video",
+		Width:        v.Width,
+		Height:       v.Height,
+		RFrameRate:   frameRate,
+		AVGFrameRate: frameRate,
+	}
+	metaData := &ffprobe.MetaData{}
+	for _, codec := range strings.Split(v.Codecs, ",") {
+		name := codecNames[strings.ToLower(strings.SplitN(strings.TrimSpace(codec), ".", 2)[0])]
+		switch name {
+		case "":
+		case "h264", "hevc":
+			video.CodecName = name
+		default:
+			metaData.Streams = append(metaData.Streams, ffprobe.StreamData{CodecType: "audio", CodecName: name})
+		}
+	}
+	metaData.Streams = append([]ffprobe.StreamData{video}, metaData.Streams...)
+
+	bandwidth := v.AverageBandwidth
+	if bandwidth == 0 {
+		bandwidth = v.Bandwidth
+	}
+	if bandwidth != 0 {
+		metaData.Format.BitRate = strconv.Itoa(bandwidth)
+	}
+	return metaData
+}
+
+// Rendition alternative media from master playlist
+// #EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="aac",LANGUAGE="ru",NAME="Русский",DEFAULT=YES,AUTOSELECT=YES,URI="audio.m3u8"
+type Rendition struct {
+	Type       string
+	GroupId    string
+	Language   string
+	Name       string
+	Default    bool
+	AutoSelect bool
+	Channels   string
+	Uri        string
+
+	Attributes *Attributes
+}
+
+func newRendition(attributes *Attributes) *Rendition {
+	return &Rendition{
+		Type:       attributes.Value("TYPE"),
+		GroupId:    attributes.Value("GROUP-ID"),
+		Language:   attributes.Value("LANGUAGE"),
+		Name:       attributes.Value("NAME"),
+		Default:    attributes.Value("DEFAULT") == "YES",
+		AutoSelect: attributes.Value("AUTOSELECT") == "YES",
+		Channels:   attributes.Value("CHANNELS"),
+		Uri:        attributes.Value("URI"),
+		Attributes: attributes,
+	}
+}
+
+// ParseAttributeList parses HLS attribute list: KEY=VALUE,KEY="QUOTED, VALUE"
+func ParseAttributeList(data string) *Attributes {
+	attributes := &Attributes{}
+
+	isSeparator := func(ch byte) bool {
+		return ch == ','
+	}
+
+	i := 0
+	for i < len(data) {
+		for i < len(data) && (isSpace(data[i]) || isSeparator(data[i])) {
+			i++
+		}
+		start := i
+		for i < len(data) && data[i] != '=' && !isSeparator(data[i]) {
+			i++
+		}
+		key := strings.TrimSpace(data[start:i])
+		if i >= len(data) || data[i] != '=' {
+			if key != "" {
+				attributes.items = append(attributes.items, Attribute{Key: key})
+			}
+			continue
+		}
+		i++
+
+		var value string
+		value, i = readValue(data, i, isSeparator)
+		if key != "" {
+			attributes.items = append(attributes.items, Attribute{Key: key, Value: value})
+		}
+	}
+	return attributes
+}
+
+func (v *Variant) attributeList() *Attributes {
+	attributes := v.Attributes.Clone()
+	if v.Bandwidth != 0 {
+		attributes.Set("BANDWIDTH", strconv.Itoa(v.Bandwidth))
+	}
+	if v.AverageBandwidth != 0 {
+		attributes.Set("AVERAGE-BANDWIDTH", strconv.Itoa(v.AverageBandwidth))
+	}
+	if v.Width != 0 && v.Height != 0 {
+		attributes.Set("RESOLUTION", strconv.Itoa(v.Width)+"x"+strconv.Itoa(v.Height))
+	}
+	if v.FrameRate != 0 && !v.IFrame {
+		attributes.Set("FRAME-RATE", strconv.FormatFloat(v.FrameRate, 'f', 3, 64))
+	}
+	setNotEmpty(attributes, "CODECS", v.Codecs)
+	setNotEmpty(attributes, "AUDIO", v.Audio)
+	setNotEmpty(attributes, "VIDEO", v.Video)
+	setNotEmpty(attributes, "SUBTITLES", v.Subtitles)
+	setNotEmpty(attributes, "CLOSED-CAPTIONS", v.ClosedCaptions)
+	if v.IFrame {
+		setNotEmpty(attributes, "URI", v.Uri)
+	}
+	return attributes
+}
+
+func (r *Rendition) attributeList() *Attributes {
+	attributes := r.Attributes.Clone()
+	setNotEmpty(attributes, "TYPE", r.Type)
+	setNotEmpty(attributes, "GROUP-ID", r.GroupId)
+	setNotEmpty(attributes, "LANGUAGE", r.Language)
+	setNotEmpty(attributes, "NAME", r.Name)
+	attributes.Set("DEFAULT", yesNo(r.Default))
+	attributes.Set("AUTOSELECT", yesNo(r.AutoSelect))
+	setNotEmpty(attributes, "CHANNELS", r.Channels)
+	setNotEmpty(attributes, "URI", r.Uri)
+	return attributes
+}
+
+func yesNo(val bool) string {
+	if val {
+		return "YES"
+	}
+	return "NO"
+}
+
+func setNotEmpty(attributes *Attributes, key string, value string) {
+	if value != "" {
+		attributes.Set(key, value)
+	}
+}
+
+// hlsQuotedKeys attributes with quoted-string values, all others are enumerated or numeric
+var hlsQuotedKeys = map[string]bool{
+	"URI": true, "CODECS": true, "AUDIO": true, "VIDEO": true, "SUBTITLES": true,
+	"GROUP-ID": true, "LANGUAGE": true, "ASSOC-LANGUAGE": true, "NAME": true, "CHANNELS": true,
+	"INSTREAM-ID": true, "CHARACTERISTICS": true, "KEYFORMAT": true, "KEYFORMATVERSIONS": true,
+	"BYTERANGE": true, "STABLE-VARIANT-ID": true, "STABLE-RENDITION-ID": true, "PATHWAY-ID": true,
+}
+
+// FormatAttributeList formats HLS attribute list
+func FormatAttributeList(attributes *Attributes) string {
+	var sb strings.Builder
+	for i, item := range attributes.Items() {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(item.Key)
+		sb.WriteByte('=')
+		key := strings.ToUpper(item.Key)
+		if hlsQuotedKeys[key] || (key == "CLOSED-CAPTIONS" && item.Value != "NONE") {
+			sb.WriteString(`"` + item.Value + `"`)
+		} else {
+			sb.WriteString(item.Value)
+		}
+	}
+	return sb.String()
+}
+
+type VariantPolicy int
+
+const (
+	VariantHighest VariantPolicy = iota
+	VariantLowest
+	VariantClosest
+)
+
+// IsMaster playlist contains variant streams instead of segments or channels
+func (m *Media) IsMaster() bool {
+	return len(m.Variants) > 0
+}
+
+// SelectVariant picks variant by policy, targetHeight used only for VariantClosest
+func (m *Media) SelectVariant(policy VariantPolicy, targetHeight int) *Variant {
+	var selected *Variant
+
+	for _, v := range m.Variants {
+		if selected == nil {
+			selected = v
+			continue
+		}
+		switch policy {
+		case VariantHighest:
+			if compareVariants(v, selected) > 0 {
+				selected = v
+			}
+		case VariantLowest:
+			if compareVariants(v, selected) < 0 {
+				selected = v
+			}
+		case VariantClosest:
+			diff := absInt(v.Height - targetHeight)
+			selectedDiff := absInt(selected.Height - targetHeight)
+			if diff < selectedDiff || (diff == selectedDiff && compareVariants(v, selected) > 0) {
+				selected = v
+			}
+		}
+	}
+	return selected
+}
+
+// compareVariants orders variants by resolution, frame rate and bandwidth
+func compareVariants(a *Variant, b *Variant) int {
+	if a.Width*a.Height != b.Width*b.Height {
+		return a.Width*a.Height - b.Width*b.Height
+	}
+	if a.FrameRate != b.FrameRate {
+		if a.FrameRate > b.FrameRate {
+			return 1
+		}
+		return -1
+	}
+	return a.Bandwidth - b.Bandwidth
+}
+
+func absInt(val int) int {
+	if val < 0 {
+		return -val
+	}
+	return val
+}
+
+// ResolveUrl resolves uri relative to playlist location
+func (m *Media) ResolveUrl(uri string) string {
+	if m.BaseUrl == "" || uri == "" {
+		return uri
+	}
+	base, err := url.Parse(m.BaseUrl)
+	if err != nil {
+		return uri
+	}
+	ref, err := url.Parse(uri)
+	if err != nil {
+		return uri
+	}
+	return base.ResolveReference(ref).String()
+}
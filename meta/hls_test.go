@@ -0,0 +1,143 @@
+package meta
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+const masterPlaylist = `#EXTM3U
+#EXT-X-VERSION:4
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="aac",LANGUAGE="ru",NAME="Русский, 2.0",DEFAULT=YES,AUTOSELECT=YES,URI="audio/ru.m3u8"
+#EXT-X-STREAM-INF:BANDWIDTH=800000,RESOLUTION=640x360,FRAME-RATE=25.000,CODECS="avc1.4d401e,mp4a.40.2",AUDIO="aac"
+low/index.m3u8
+#EXT-X-STREAM-INF:BANDWIDTH=5000000,RESOLUTION=1920x1080,FRAME-RATE=50.000,CODECS="avc1.640028,mp4a.40.2",AUDIO="aac"
+hi/index.m3u8
+#EXT-X-STREAM-INF:BANDWIDTH=2500000,RESOLUTION=1280x720,FRAME-RATE=25.000,CODECS="avc1.64001f,mp4a.40.2",AUDIO="aac"
+/mid/index.m3u8
+#EXT-X-I-FRAME-STREAM-INF:BANDWIDTH=86000,RESOLUTION=640x360,URI="low/iframe.m3u8"
+`
+
+func TestMasterPlaylist(t *testing.T) {
+	media, err := readRecords(strings.NewReader(masterPlaylist), nil)
+	if err != nil {
+		t.Fatalf("readRecords failed: %v", err)
+	}
+	media.BaseUrl = "http://host/live/channel/master.m3u8"
+
+	if !media.IsMaster() || len(media.Variants) != 3 {
+		t.Fatalf("expected 3 variants, got %d", len(media.Variants))
+	}
+	if len(media.IFrameVariants) != 1 || media.IFrameVariants[0].Uri != "low/iframe.m3u8" {
+		t.Fatalf("invalid iframe variants: %+v", media.IFrameVariants)
+	}
+	if len(media.Renditions) != 1 || media.Renditions[0].Name != "Русский, 2.0" || !media.Renditions[0].Default {
+		t.Fatalf("invalid renditions: %+v", media.Renditions)
+	}
+	if len(media.Records) != 0 {
+		t.Fatalf("variants must not be parsed as records")
+	}
+
+	hi := media.SelectVariant(VariantHighest, 0)
+	if hi.Height != 1080 || hi.FrameRate != 50 || hi.Codecs != "avc1.640028,mp4a.40.2" {
+		t.Fatalf("invalid highest variant: %+v", hi)
+	}
+	if u := media.ResolveUrl(hi.Uri); u != "http://host/live/channel/hi/index.m3u8" {
+		t.Fatalf("invalid resolved url: %s", u)
+	}
+	if low := media.SelectVariant(VariantLowest, 0); low.Height != 360 {
+		t.Fatalf("invalid lowest variant: %+v", low)
+	}
+	closest := media.SelectVariant(VariantClosest, 700)
+	if closest.Height != 720 {
+		t.Fatalf("invalid closest variant: %+v", closest)
+	}
+	if u := media.ResolveUrl(closest.Uri); u != "http://host/mid/index.m3u8" {
+		t.Fatalf("invalid resolved url: %s", u)
+	}
+
+	stream := hi.MetaData().GetVideoStream()
+	if stream.Width != 1920 || stream.RFrameRate.RoundedQuotient() != 50 {
+		t.Fatalf("invalid manifest meta: %+v", stream)
+	}
+}
+
+func TestMediaPlaylistRoundTrip(t *testing.T) {
+	source := `#EXTM3U
+#EXT-X-VERSION:6
+#EXT-X-TARGETDURATION:10
+#EXT-X-MEDIA-SEQUENCE:20456
+#EXT-X-PLAYLIST-TYPE:EVENT
+#EXT-X-KEY:METHOD=AES-128,URI="https://keys/1",IV=0x0102
+#EXT-X-MAP:URI="init.mp4",BYTERANGE="720@0"
+#EXT-X-PROGRAM-DATE-TIME:2024-07-23T21:45:55.000Z
+#EXTINF:10,
+#EXT-X-BYTERANGE:1000@720
+main.mp4
+#EXTINF:9.5,second
+#EXT-X-BYTERANGE:800
+main.mp4
+#EXT-X-KEY:METHOD=NONE
+#EXT-X-DISCONTINUITY
+#EXTINF:4.004,
+ad/1.ts
+#EXT-X-ENDLIST
+`
+	media, err := readRecords(strings.NewReader(source), nil)
+	if err != nil {
+		t.Fatalf("readRecords failed: %v", err)
+	}
+	if !media.IsMediaPlaylist() || media.IsMaster() {
+		t.Fatalf("invalid playlist type")
+	}
+	if media.Version != 6 || media.TargetDuration != 10 || media.MediaSequence != 20456 || !media.EndList || media.PlaylistType != "EVENT" {
+		t.Fatalf("invalid playlist header: %+v", media)
+	}
+	if len(media.Segments) != 3 {
+		t.Fatalf("expected 3 segments, got %d", len(media.Segments))
+	}
+
+	first := media.Segments[0]
+	if first.Key == nil || first.Key.Method != "AES-128" || first.Map == nil || first.Map.ByteRange.Length != 720 {
+		t.Fatalf("invalid first segment: %+v", first)
+	}
+	if first.ByteRange.Offset != 720 || first.ProgramDateTime.IsZero() {
+		t.Fatalf("invalid first segment: %+v", first)
+	}
+	if second := media.Segments[1]; second.Key != first.Key || second.Title != "second" || second.ByteRange.HasOffset {
+		t.Fatalf("invalid second segment: %+v", second)
+	}
+	if third := media.Segments[2]; third.Key != nil || !third.Discontinuity || third.Duration != 4.004 {
+		t.Fatalf("invalid third segment: %+v", third)
+	}
+	if d := media.TotalDuration(); d.Milliseconds() != 23504 {
+		t.Fatalf("invalid total duration: %v", d)
+	}
+
+	var sb strings.Builder
+	err = media.WritePlaylist(&sb)
+	if err != nil {
+		t.Fatalf("WritePlaylist failed: %v", err)
+	}
+	if sb.String() != source {
+		t.Fatalf("unexpected playlist:\n%s", sb.String())
+	}
+}
+
+func TestParseProgramDateTime(t *testing.T) {
+	expected := time.Date(2024, 7, 23, 21, 45, 55, 0, time.UTC)
+	for _, value := range []string{"2024-07-23T21:45:55.000Z", "2024-07-23T21:45:55Z", "2024-07-23T21:45:55.000+0000", "2024-07-24T00:45:55+0300"} {
+		if dt, err := parseProgramDateTime(value); err != nil || !dt.Equal(expected) {
+			t.Fatalf("invalid program date time of %s: %v %v", value, dt, err)
+		}
+	}
+	if _, err := parseProgramDateTime("2024-07-23 21:45:55"); err == nil {
+		t.Fatalf("invalid program date time must fail")
+	}
+
+	media, err := readRecords(strings.NewReader("#EXTM3U\n#EXT-X-TARGETDURATION:10\n#EXT-X-PROGRAM-DATE-TIME:2024-07-23T21:45:55.000+0000\n#EXTINF:10,\n1.ts\n"),
+		&ReadOptions{Strict: true})
+	if err != nil || len(media.Segments) != 1 || !media.Segments[0].ProgramDateTime.Equal(expected) {
+		t.Fatalf("offset without colon must be parsed in strict mode: %v", err)
+	}
+}
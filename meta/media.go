@@ -2,71 +2,91 @@ package meta
 
 import (
 	"bufio"
+	"context"
 	"errors"
 	log "github.com/sirupsen/logrus"
 	"io"
 	"m3u8/cfg"
 	"m3u8/util"
-	"net/http"
 	"os"
 	"regexp"
+	"strconv"
 	"strings"
-	"sync"
-	"time"
 )
 
+const (
+	GroupSourceExtGrp     = "extgrp"      // #EXTGRP:кино
+	GroupSourceGroupTitle = "group-title" // #EXTINF:0 group-title="кино",Name
+)
+
+// DefaultGroupSources group resolution precedence if list has no own config
+var DefaultGroupSources = []string{GroupSourceExtGrp, GroupSourceGroupTitle}
+
 type Record struct {
-	GroupName string // #EXTGRP:HD
-	NameData  string // #EXTINF:0,Россия HD / #EXTINF:10.000000,
+	GroupName string   // #EXTGRP:HD
+	NameData  string   // #EXTINF:0,Россия HD / #EXTINF:10.000000,
+	Tags      []string // #EXTVLCOPT:http-user-agent=... / #KODIPROP:... / #EXTHTTP:{...}
 	Url       string
+	Line      int    // #EXTINF line number
+	RemoteId  string // Known remote id for records built from provider api, url profile is used if empty
+	// Programmes known upcoming programmes for records built from provider api
+	Programmes []Programme
 }
 
 func (r *Record) IsFilled() bool {
 	return r.Url != "" && r.NameData != ""
 }
 
-type multiGroup struct {
-	mux             sync.Mutex
-	lowResChannels  []*Channel
-	highResChannels []*Channel
-}
-
-func (m *multiGroup) AddChannel(chnl *Channel) {
-	m.mux.Lock()
-	defer m.mux.Unlock()
-
-	if chnl.Width >= 1920 || chnl.Height >= 1080 {
-		m.highResChannels = append(m.highResChannels, chnl)
-		return
-	}
-	m.lowResChannels = append(m.lowResChannels, chnl)
-}
-
-func (m *multiGroup) Contains(channelName string) bool {
-	m.mux.Lock()
-	defer m.mux.Unlock()
-
-	for _, channel := range m.lowResChannels {
-		if channel.Name == channelName {
-			return true
-		}
-	}
-	for _, channel := range m.highResChannels {
-		if channel.Name == channelName {
-			return true
-		}
-	}
-	return false
-}
-
 type Media struct {
 	forceReloadChannelData bool
 	noSampleLoad           bool
 	validFileType          bool
 
-	Version        string // #EXT-X-VERSION:3
-	MediaSequence  string // #EXT-X-MEDIA-SEQUENCE:20456
-	TargetDuration string // #EXT-X-TARGETDURATION:11
+	strict     bool
+	lineNumber int
+	report     *ParseReport
+
+	groupSources  []string
+	fallbackGroup string
+
+	prober      Prober
+	freshness   *Freshness
+	metaCache   *MetaCache
+	healthCheck bool
+
+	dedupMode       string
+	backupAttribute string
+	// selectRule ranks duplicates by source priority or stream quality first
+	selectRule string
+
+	source         string
+	sourcePriority int
+
+	Version               int    // #EXT-X-VERSION:3
+	MediaSequence         int64  // #EXT-X-MEDIA-SEQUENCE:20456
+	TargetDuration        int    // #EXT-X-TARGETDURATION:11
+	DiscontinuitySequence int64  // #EXT-X-DISCONTINUITY-SEQUENCE:3
+	PlaylistType          string // #EXT-X-PLAYLIST-TYPE:VOD
+	EndList               bool   // #EXT-X-ENDLIST
+	IndependentSegments   bool   // #EXT-X-INDEPENDENT-SEGMENTS
+
+	// Attributes from #EXTM3U header line
+	Attributes *Attributes
+	// BaseUrl playlist location for relative uri resolving
+	BaseUrl string
+
+	// Master playlist variants and renditions
+	Variants       []*Variant
+	IFrameVariants []*Variant
+	Renditions     []*Rendition
+	pendingVariant *Variant
+
+	// Media playlist segments
+	Segments       []*Segment
+	mediaPlaylist  bool
+	pendingSegment *Segment
+	currentKey     *Key
+	currentMap     *MediaMap
 
 	Records []*Record
 
@@ -81,33 +101,65 @@ func (m *Media) lastRecord() *Record {
 	return m.Records[len(m.Records)-1]
 }
 
-func (m *Media) addGroup(record *Record) {
+func (m *Media) addGroup(record *Record, groupName string) {
 	if !record.IsFilled() {
 		return
 	}
-	var group *Group
-	for i := 0; i < len(m.Groups); i++ {
-		if m.Groups[i].Name == record.GroupName {
-			group = m.Groups[i]
-		}
-	}
-	if group == nil {
-		group = &Group{
-			Name:     record.GroupName,
-			Channels: nil,
-		}
-		m.Groups = append(m.Groups, group)
-	}
+	group := m.CreateGroup(groupName)
 
 	channel := Channel{
 		Url:             record.Url,
+		Tags:            record.Tags,
+		RemoteId:        record.RemoteId,
+		Programmes:      record.Programmes,
 		ForceReloadData: m.forceReloadChannelData,
 		NoSampleLoad:    m.noSampleLoad,
+		prober:          m.getProber(),
+		freshness:       m.freshness,
+		metaCache:       m.metaCache,
+		healthCheck:     m.healthCheck,
+		Source:          m.source,
+		SourcePriority:  m.sourcePriority,
+		SourceGroup:     groupName,
 	}
-	channel.SetName(record.NameData, record.GroupName)
+	// Meta is loaded later by LoadChannels
+	channel.parseName(record.NameData)
 
 	group.Channels = append(group.Channels, &channel)
 }
+
+// LoadChannels loads meta of all channels concurrently with scheduler, config scheduler is used if nil
+func (m *Media) LoadChannels(ctx context.Context, scheduler *ProbeScheduler) error {
+	if scheduler == nil {
+		scheduler = NewProbeScheduler()
+	}
+
+	var tasks []ProbeTask
+	for _, group := range m.Groups {
+		groupName := group.Name
+		for _, channel := range group.Channels {
+			if channel == nil || channel.RemoteId == "" {
+				continue
+			}
+			c := channel
+			tasks = append(tasks, ProbeTask{
+				Host: c.Provider.Host,
+				Run: func(ctx context.Context) {
+					c.LoadData(ctx, groupName)
+				},
+			})
+		}
+	}
+	return scheduler.Run(ctx, tasks)
+}
+
+func (m *Media) getProber() Prober {
+	if m.prober == nil {
+		m.prober = DefaultProber()
+	}
+	return m.prober
+}
+
 func (m *Media) CreateGroup(name string) *Group {
 	group, _ := m.FindGroup(name)
 	if group == nil {
@@ -129,160 +181,233 @@ func (m *Media) FindGroup(name string) (*Group, int) {
 	return nil, -1
 }
 
+// diagnose adds diagnostic to parse report, returns error if it must stop strict parsing
+func (m *Media) diagnose(line int, tag string, severity Severity, message string) error {
+	if m.report == nil {
+		m.report = &ParseReport{}
+	}
+	d := m.report.add(line, tag, severity, message)
+	if m.strict && severity == SeverityError {
+		return &DiagnosticError{Diagnostic: d}
+	}
+	return nil
+}
+
+// channelTags auxiliary tags of next channel kept for lossless output
+var channelTags = []string{"#EXTVLCOPT:", "#KODIPROP:", "#EXTHTTP:"}
+
+func isChannelTag(line string) bool {
+	for _, tag := range channelTags {
+		if strings.HasPrefix(line, tag) {
+			return true
+		}
+	}
+	return false
+}
+
 func (m *Media) AddLine(line string) error {
+	m.lineNumber++
 
 	if strings.HasPrefix(line, "#EXTM3U") {
 		m.validFileType = true
+		m.Attributes = ParseAttributes(strings.TrimSpace(line[len("#EXTM3U"):]))
 		// We are at begin of processing file
 		return nil
 	}
 	if !m.validFileType {
+		_ = m.diagnose(m.lineNumber, lineTag(line), SeverityError, "missing #EXTM3U header")
 		return errors.New("invalid file type with first line: " + line)
 	}
+	if strings.TrimSpace(line) == "" {
+		return nil
+	}
+
+	// Media playlist tags
+	processed, err := m.addPlaylistLine(line)
+	if err != nil {
+		return m.diagnose(m.lineNumber, lineTag(line), SeverityError, err.Error())
+	}
+	if processed {
+		return nil
+	}
 
-	if strings.HasPrefix(line, "#EXT-X-VERSION:") {
-		m.Version = line[len("#EXT-X-VERSION:"):]
+	// Master playlist tags
+	if strings.HasPrefix(line, "#EXT-X-STREAM-INF:") {
+		if m.pendingVariant != nil {
+			err = m.diagnose(m.lineNumber, "#EXT-X-STREAM-INF", SeverityError, "previous variant has no uri")
+		}
+		m.pendingVariant = newVariant(ParseAttributeList(line[len("#EXT-X-STREAM-INF:"):]), false)
+		return err
+	}
+	if strings.HasPrefix(line, "#EXT-X-I-FRAME-STREAM-INF:") {
+		m.IFrameVariants = append(m.IFrameVariants, newVariant(ParseAttributeList(line[len("#EXT-X-I-FRAME-STREAM-INF:"):]), true))
 		return nil
 	}
-	if strings.HasPrefix(line, "#EXT-X-MEDIA-SEQUENCE:") {
-		m.MediaSequence = line[len("#EXT-X-MEDIA-SEQUENCE:"):]
+	if strings.HasPrefix(line, "#EXT-X-MEDIA:") {
+		m.Renditions = append(m.Renditions, newRendition(ParseAttributeList(line[len("#EXT-X-MEDIA:"):])))
 		return nil
 	}
-	if strings.HasPrefix(line, "#EXT-X-TARGETDURATION:") {
-		m.TargetDuration = line[len("#EXT-X-TARGETDURATION:"):]
+	if m.pendingVariant != nil && !strings.HasPrefix(line, "#") {
+		// Variant uri follows #EXT-X-STREAM-INF
+		m.pendingVariant.Uri = line
+		m.Variants = append(m.Variants, m.pendingVariant)
+		m.pendingVariant = nil
 		return nil
 	}
 
+	if strings.HasPrefix(line, "#") && !strings.HasPrefix(line, "#EXTINF:") && !strings.HasPrefix(line, "#EXTGRP:") &&
+		!isChannelTag(line) {
+		// Comments and unsupported tags are not bound to channels
+		if strings.HasPrefix(line, "#EXT") {
+			return m.diagnose(m.lineNumber, lineTag(line), SeverityWarning, "unsupported tag is skipped")
+		}
+		return m.diagnose(m.lineNumber, "", SeverityInfo, "comment is skipped")
+	}
+
 	var record = m.lastRecord()
 
-	if record == nil || record.IsFilled() {
+	if record == nil || record.IsFilled() || record.Url != "" {
 		record = &Record{}
 		m.Records = append(m.Records, record)
 	}
 
 	// Record tags
 	if strings.HasPrefix(line, "#EXTINF:") {
+		if record.NameData != "" {
+			// Previous #EXTINF is left without uri
+			err = m.diagnose(record.Line, "#EXTINF", SeverityError, "entry has no uri")
+			record = &Record{}
+			m.Records = append(m.Records, record)
+		}
 		// #EXTINF:0,Первый HD
 		record.NameData = line[len("#EXTINF:"):]
+		record.Line = m.lineNumber
+		if !m.mediaPlaylist && ParseExtInf(record.NameData).Title == "" {
+			if dErr := m.diagnose(m.lineNumber, "#EXTINF", SeverityWarning, "empty channel name"); err == nil {
+				err = dErr
+			}
+		}
 	} else if strings.HasPrefix(line, "#EXTGRP:") {
+		if record.GroupName != "" {
+			err = m.diagnose(m.lineNumber, "#EXTGRP", SeverityWarning, "duplicate group, previous value \""+record.GroupName+"\" is replaced")
+		}
 		// #EXTGRP:HD
 		record.GroupName = line[len("#EXTGRP:"):]
-	} else if strings.HasPrefix(line, "#") {
-		log.Println("Unknown tag: " + line)
+	} else if isChannelTag(line) {
+		// Auxiliary tags are kept as is for lossless output
+		record.Tags = append(record.Tags, line)
 	} else {
+		if record.NameData == "" {
+			err = m.diagnose(m.lineNumber, "URI", SeverityError, "uri without #EXTINF: "+line)
+		}
 		record.Url = line
 	}
 
-	return nil
-}
-
-func (m *Media) SortGroup(groupName string) {
-	group, _ := m.FindGroup(groupName)
-	if group == nil {
-		return
-	}
-	group.sortChannels()
+	return err
 }
 
-func (m *Media) CheckHighRes(groupName string, fullSearch bool, threads int) {
-	lowResGroup, _ := m.FindGroup(groupName)
-	if lowResGroup == nil {
-		return
+// finish verifies playlist state after last line
+func (m *Media) finish() error {
+	if m.report == nil {
+		m.report = &ParseReport{}
 	}
+	m.report.Lines = m.lineNumber
 
-	highResGroupName := groupName + " HD"
-	hiResGroup, _ := m.FindGroup(highResGroupName)
-	if hiResGroup == nil {
-		// Add group?
-		hiResGroup = m.CreateGroup(highResGroupName)
+	if m.pendingVariant != nil {
+		return m.diagnose(m.lineNumber, "#EXT-X-STREAM-INF", SeverityError, "last variant has no uri")
 	}
-	var separated multiGroup
-	if fullSearch {
-		lowResGroup.mergeChannels(hiResGroup)
-	} else {
-		separated.highResChannels = append(separated.highResChannels, hiResGroup.Channels...)
-	}
-
-	for _, channel := range lowResGroup.Channels {
-		separated.AddChannel(channel)
+	record := m.lastRecord()
+	if record != nil && record.NameData != "" && record.Url == "" {
+		return m.diagnose(record.Line, "#EXTINF", SeverityError, "entry has no uri")
 	}
-
-	lowResGroup.Channels = separated.lowResChannels
-	hiResGroup.Channels = separated.highResChannels
+	return nil
 }
 
-func ReadUrl(url string, forceReloadChannelData bool, noSampleLoad bool) *Media {
-
-	http.DefaultClient.Timeout = 10 * time.Second
-	resp, err := http.Get(url)
-	if err != nil {
-		log.Printf("Failed to load playlist %s metadata: %v\n", url, err)
-		return nil
-	}
-	if resp == nil || resp.Body == nil {
-		log.Printf("Failed to load playlist %s metadata: zero response\n", url)
-		return nil
-	}
-
-	var media *Media
-	media, err = readRecords(resp.Body)
-	media.forceReloadChannelData = forceReloadChannelData
-	media.noSampleLoad = noSampleLoad
-	_ = resp.Body.Close()
-
-	if err != nil {
-		log.Printf("Failed to read url %s data: %v\n", url, err)
-		return nil
-	}
-
-	if media != nil {
-		media.structRecords()
+func (m *Media) SortGroup(groupName string) {
+	group, _ := m.FindGroup(groupName)
+	if group == nil {
+		return
 	}
-
-	return media
+	group.sortChannels()
 }
 
-func readRecords(r io.Reader) (*Media, error) {
-	var media Media
-
-	sc := bufio.NewScanner(r)
-	var err error
-
-	for sc.Scan() {
-		err = media.AddLine(sc.Text())
-		if err != nil {
-			return &media, err
+// resolveGroupName takes record group from sources by precedence or fallback group
+func (m *Media) resolveGroupName(record *Record) string {
+	sources := m.groupSources
+	if len(sources) == 0 {
+		sources = DefaultGroupSources
+	}
+
+	for _, source := range sources {
+		var groupName string
+		switch source {
+		case GroupSourceExtGrp:
+			groupName = record.GroupName
+		case GroupSourceGroupTitle:
+			groupName = ParseExtInf(record.NameData).Attributes.Value("group-title")
+		default:
+			log.Warnf("Unknown group source: %s", source)
+		}
+		groupName = strings.TrimSpace(groupName)
+		if groupName != "" {
+			return groupName
 		}
 	}
-
-	return &media, sc.Err()
+	return m.fallbackGroup
 }
 
 func (m *Media) structRecords() {
 	for _, record := range m.Records {
 		if record.IsFilled() {
-			if record.GroupName != "" {
+			groupName := m.resolveGroupName(record)
+			if groupName != "" {
 				// We found channel group
-				m.addGroup(record)
+				m.addGroup(record, groupName)
 			}
 		}
 	}
 }
 
-func (m *Media) WriteFiles(filePaths []string, epgUrl string, skipGroups []string) {
-	for _, path := range filePaths {
-		if len(path) == 0 {
+func (m *Media) WriteFiles(outputs []cfg.Output, epgUrl string) {
+	for i := range outputs {
+		if len(outputs[i].FileName) == 0 {
 			continue
 		}
-		m.WriteFile(path, epgUrl, skipGroups)
+		m.WriteFile(&outputs[i], epgUrl)
+	}
+}
+
+func (m *Media) getHeader(epgUrl string, lossless bool) string {
+	if !lossless {
+		if epgUrl == "" {
+			return "#EXTM3U"
+		}
+		return "#EXTM3U x-tvg-url=\"" + epgUrl + "\""
+	}
+
+	attributes := m.Attributes.Clone()
+	if epgUrl != "" {
+		attributes.Set("x-tvg-url", epgUrl)
+	}
+	if attributes.Len() == 0 {
+		return "#EXTM3U"
 	}
+	return "#EXTM3U " + attributes.String()
 }
 
-func (m *Media) WriteFile(filePath string, epgUrl string, skipGroups []string) {
-	if filePath == "" {
+func (m *Media) WriteFile(output *cfg.Output, epgUrl string) {
+	if output == nil || output.FileName == "" {
 		log.Errorf("empty file path")
 		return
 	}
+	filePath := output.FileName
+
+	// Broken filters keep previous file untouched
+	view, err := newOutputView(output)
+	if err != nil {
+		log.Errorf("invalid output %s: %v", filePath, err)
+		return
+	}
 
 	f, err := os.Create(filePath)
 
@@ -295,50 +420,166 @@ func (m *Media) WriteFile(filePath string, epgUrl string, skipGroups []string) {
 		return
 	}
 
-	if epgUrl == "" {
-		_, err = f.WriteString("#EXTM3U\n")
-	} else {
-		_, err = f.WriteString("#EXTM3U x-tvg-url=\"" + epgUrl + "\"\n")
+	w := bufio.NewWriter(f)
+	err = m.writeView(w, view, epgUrl, loadOutputNumbering(output))
+	if err == nil {
+		err = w.Flush()
 	}
 
 	if err != nil {
 		log.Errorf("failed to write to file: %s with error: %+v", filePath, err)
 		return
 	}
+	log.Infof("Wrote %s", filePath)
+}
+
+func (m *Media) write(w io.Writer, output *cfg.Output, epgUrl string) error {
+	view, err := newOutputView(output)
+	if err != nil {
+		return err
+	}
+	return m.writeView(w, view, epgUrl, loadOutputNumbering(output))
+}
+
+func loadOutputNumbering(output *cfg.Output) *Numbering {
+	if !output.Numbering {
+		return nil
+	}
+	return LoadNumbering(output.NumberingScope)
+}
+
+// outputRecord channel of output group, numbered by its media group
+type outputRecord struct {
+	numberedChannel
+	outputGroup string
+	censored    bool
+}
+
+// writeView writes output of view, channel numbers are assigned by numbering if set
+func (m *Media) writeView(w io.Writer, view *outputView, epgUrl string, numbering *Numbering) error {
+	output := view.output
+	_, err := io.WriteString(w, m.getHeader(epgUrl, output.Lossless)+"\n")
+	if err != nil {
+		return err
+	}
 
+	var records, deadRecords, censoredRecords []*outputRecord
+	parental := getParental()
 	for _, group := range m.Groups {
-		if util.Contains(skipGroups, group.Name) {
+		groupName, ok := view.group(group.Name)
+		if !ok {
 			continue
 		}
 
-		censored := strings.Contains(group.Name, "взрослые")
-
 		for _, channel := range group.Channels {
-
-			//  #EXTINF:0,Первый HD
-			_, err = f.WriteString(channel.GetInfoData(censored) + "\n")
-			if err != nil {
-				log.Errorf("failed to write to file: %s with error: %+v", filePath, err)
-				return
+			censored := parental.IsCensored(channel, group.Name)
+			if censored && output.CensoredMode == cfg.CensoredModeDrop {
+				continue
 			}
-			// #EXTGRP:HD
-			_, err = f.WriteString("#EXTGRP:" + group.Name + "\n")
-			if err != nil {
-				log.Errorf("failed to write to file: %s with error: %+v", filePath, err)
-				return
+			channel, ok := view.primary(channel)
+			if !ok {
+				continue
 			}
-			// URL
-			_, err = f.WriteString(channel.Url + "\n")
-
-			if err != nil {
-				log.Errorf("failed to write to file: %s with error: %+v", filePath, err)
-				return
+			record := &outputRecord{numberedChannel{groupName: group.Name, channel: channel}, groupName, censored}
+			if isDeadOutput(output) && channel.IsDead(output.DeadFailures) {
+				if output.DeadMode == cfg.DeadModeMove {
+					record.outputGroup = output.DeadGroup
+					deadRecords = append(deadRecords, record)
+				}
+				continue
+			}
+			if censored && output.CensoredMode == cfg.CensoredModeMove && groupName != output.CensoredGroup {
+				record.outputGroup = output.CensoredGroup
+				censoredRecords = append(censoredRecords, record)
+				continue
 			}
+			records = append(records, record)
 		}
 	}
-	log.Infof("Wrote %s", filePath)
+
+	// Moved channels follow all groups, censored group comes before dead one
+	for _, moved := range [][]*outputRecord{censoredRecords, deadRecords} {
+		if len(moved) != 0 && !util.Contains(output.SkipGroups, moved[0].outputGroup) {
+			records = append(records, moved...)
+		}
+	}
+
+	if numbering != nil {
+		numbered := make([]*numberedChannel, len(records))
+		for i, record := range records {
+			numbered[i] = &record.numberedChannel
+		}
+		numbering.store(numbering.assign(numbered))
+	}
+
+	for _, record := range records {
+		err = m.writeChannel(w, view, record)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func isDeadOutput(output *cfg.Output) bool {
+	return output.DeadMode == cfg.DeadModeExclude || output.DeadMode == cfg.DeadModeMove
+}
+
+// writeChannel writes channel record with its backups according to dedup mode
+func (m *Media) writeChannel(w io.Writer, view *outputView, record *outputRecord) error {
+	groupName, channel, censored := record.outputGroup, record.channel, record.censored
+	backups := m.writtenBackups(view, channel)
+	extra := &Attributes{}
+	if record.number != 0 {
+		extra.Set("tvg-chno", strconv.Itoa(record.number))
+	}
+	if m.dedupMode == cfg.DedupModeAttribute && m.backupAttribute != "" && len(backups) != 0 {
+		extra.Set(m.backupAttribute, backupUrls(backups))
+	}
+
+	err := writeRecord(w, view.output, groupName, channel, censored, extra)
+	if err != nil || m.dedupMode != cfg.DedupModeSeparate {
+		return err
+	}
+	for _, backup := range backups {
+		err = writeRecord(w, view.output, groupName, backup, censored, nil)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeRecord(w io.Writer, output *cfg.Output, groupName string, channel *Channel, censored bool, extra *Attributes) error {
+	lines := make([]string, 0, 3+len(channel.Tags))
+
+	//  #EXTINF:0,Первый HD
+	if output.Lossless {
+		lines = append(lines, channel.getLosslessInfoData(groupName, censored, extra))
+	} else {
+		lines = append(lines, channel.getInfoData(censored, extra))
+	}
+	// #EXTGRP:HD
+	lines = append(lines, "#EXTGRP:"+groupName)
+	// #EXTVLCOPT:... / #KODIPROP:...
+	if output.Lossless {
+		lines = append(lines, channel.Tags...)
+	}
+	// URL
+	lines = append(lines, channel.Url)
+
+	for _, line := range lines {
+		_, err := io.WriteString(w, line+"\n")
+		if err != nil {
+			return err
+		}
+	}
+	return nil
 }
-func (m *Media) forceChannels(groupName string, channelNames []string) {
+
+// forceChannels moves channels matching names by normalized name to group,
+// anyQuality matches other quality of channel by canonical name if there is no normalized match
+func (m *Media) forceChannels(groupName string, channelNames []string, anyQuality bool) {
 	if groupName == "" {
 		return
 	}
@@ -356,17 +597,29 @@ func (m *Media) forceChannels(groupName string, channelNames []string) {
 	channels := make([]*Channel, 0, len(channelNames))
 
 	for _, chnl := range channelNames {
-		for _, g := range m.Groups {
-			if g.Name != group.Name {
-				for i := len(g.Channels) - 1; i >= 0; i-- {
-					channel := g.Channels[i]
-					if strings.ToLower(channel.Name) == strings.ToLower(chnl) {
-						// No breaking here, there can be multiple channels with same name!
-						g.extractChannel(i)
-						channels = append(channels, channel)
+		// Normalized match in any group wins over canonical one
+		matches := []func(string) string{NormalizeName}
+		if anyQuality {
+			matches = append(matches, CanonicalName)
+		}
+		found := len(channels)
+		for _, match := range matches {
+			name := match(chnl)
+			for _, g := range m.Groups {
+				if g.Name != group.Name {
+					for i := len(g.Channels) - 1; i >= 0; i-- {
+						channel := g.Channels[i]
+						if match(channel.Name) == name {
+							// No breaking here, there can be multiple channels with same name!
+							g.extractChannel(i)
+							channels = append(channels, channel)
+						}
 					}
 				}
 			}
+			if len(channels) != found {
+				break
+			}
 		}
 	}
 	group.Channels = append(group.Channels, channels...)
@@ -384,13 +637,14 @@ func (m *Media) ApplyGroupsForcing() {
 			name := util.GetValue("name", item.(map[string]interface{}), "")
 
 			force := util.GetValueArray("force", item.(map[string]interface{}), []string{})
+			anyQuality := util.GetValue("force_any_quality", item.(map[string]interface{}), false)
 			begin := util.GetValueArray("begin", item.(map[string]interface{}), []string{})
 			end := util.GetValueArray("end", item.(map[string]interface{}), []string{})
 
 			force = append(force, begin...)
 			force = append(force, end...)
 
-			m.forceChannels(name, force)
+			m.forceChannels(name, force, anyQuality)
 
 			break
 		}
@@ -422,17 +676,7 @@ func (m *Media) FilterForeign(groupName string) {
 		return
 	}
 
-	foreignGroup := m.CreateGroup("иностранные")
-
-	for n := len(group.Channels) - 1; n >= 0; n-- {
-		last := len(group.Channels) - 1
-		channel := group.Channels[n]
-		if reg.Match([]byte(channel.Name)) {
-			foreignGroup.Channels = append(foreignGroup.Channels, channel)
-			group.Channels[n] = group.Channels[last]
-			group.Channels = group.Channels[:last]
-		}
-	}
+	m.ApplyRules([]*Rule{{Group: "иностранные", Action: RuleActionMove, Match: reg, Groups: []string{group.Name}}})
 	/*
 
 		// To detect all
@@ -488,26 +732,6 @@ func (m *Media) PrintGroups() {
 	log.Println("]")
 }
 
-func (m *Media) ValidateHighRes() {
-	validationList := make([]string, 0, 10)
-
-	groupsConf := cfg.GetHDSplit()
-
-	for _, g := range groupsConf {
-		group, _ := m.FindGroup(g)
-		if group == nil {
-			continue
-		}
-
-		if !strings.Contains(group.Name, "HD") && !strings.Contains(group.Name, "4K") {
-			validationList = append(validationList, group.Name)
-		}
-	}
-	for _, groupName := range validationList {
-		m.CheckHighRes(groupName, true, 2)
-	}
-}
-
 func (m *Media) SortGroups() {
 	for _, group := range m.Groups {
 		group.sortChannels()
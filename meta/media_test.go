@@ -0,0 +1,77 @@
+package meta
+
+import (
+	"bytes"
+	"m3u8/cfg"
+	"strings"
+	"testing"
+)
+
+func TestLosslessWrite(t *testing.T) {
+	source := `#EXTM3U url-tvg="http://epg/1.xml"
+#EXTINF:-1 tvg-id="kino" catchup="default" catchup-source="?utc={utc}" catchup-days="3" group-title="Кино",Кино HD
+#EXTVLCOPT:http-user-agent=Mozilla
+# provider comment
+#EXT-X-UNKNOWN:1
+#KODIPROP:inputstream=inputstream.adaptive
+http://host/kino/index.m3u8
+`
+	media, err := readRecords(strings.NewReader(source), nil)
+	if err != nil {
+		t.Fatalf("readRecords failed: %v", err)
+	}
+	if len(media.Records) != 1 || len(media.Records[0].Tags) != 2 {
+		t.Fatalf("auxiliary tags are not kept: %+v", media.Records)
+	}
+	if media.report.Count(SeverityInfo) != 1 || media.report.Count(SeverityWarning) != 1 {
+		t.Fatalf("skipped lines must be reported: %+v", media.report)
+	}
+
+	record := media.Records[0]
+	extInf := ParseExtInf(record.NameData)
+	channel := &Channel{Url: record.Url, Tags: record.Tags, Name: extInf.Title}
+	channel.setData(extInf)
+	media.Groups = []*Group{{Name: "кино HD", Channels: []*Channel{channel}}}
+
+	var buf bytes.Buffer
+	err = media.write(&buf, &cfg.Output{Lossless: true}, "http://epg/2.xml")
+	if err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+
+	expected := `#EXTM3U url-tvg="http://epg/1.xml" x-tvg-url="http://epg/2.xml"
+#EXTINF:-1 tvg-id="kino" catchup="default" catchup-source="?utc={utc}" catchup-days="3" group-title="кино HD" tvg-rec="3",Кино HD
+#EXTGRP:кино HD
+#EXTVLCOPT:http-user-agent=Mozilla
+#KODIPROP:inputstream=inputstream.adaptive
+http://host/kino/index.m3u8
+`
+	if buf.String() != expected {
+		t.Fatalf("unexpected output:\n%s", buf.String())
+	}
+}
+
+func TestResolveGroupName(t *testing.T) {
+	withBoth := &Record{GroupName: "HD", NameData: `0 group-title="Кино",Name`, Url: "http://host/1"}
+	titleOnly := &Record{NameData: `0 group-title="Кино",Name`, Url: "http://host/2"}
+	none := &Record{NameData: `0,Name`, Url: "http://host/3"}
+
+	media := &Media{}
+	if g := media.resolveGroupName(withBoth); g != "HD" {
+		t.Fatalf("default precedence must prefer #EXTGRP, got %s", g)
+	}
+	if g := media.resolveGroupName(titleOnly); g != "Кино" {
+		t.Fatalf("group-title must be used without #EXTGRP, got %s", g)
+	}
+	if g := media.resolveGroupName(none); g != "" {
+		t.Fatalf("record without group must be skipped, got %s", g)
+	}
+
+	media = &Media{groupSources: []string{GroupSourceGroupTitle, GroupSourceExtGrp}, fallbackGroup: "другие"}
+	if g := media.resolveGroupName(withBoth); g != "Кино" {
+		t.Fatalf("configured precedence must prefer group-title, got %s", g)
+	}
+	if g := media.resolveGroupName(none); g != "другие" {
+		t.Fatalf("fallback group expected, got %s", g)
+	}
+}
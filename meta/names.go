@@ -0,0 +1,152 @@
+package meta
+
+import (
+	log "github.com/sirupsen/logrus"
+	"m3u8/cfg"
+	"m3u8/db"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// homoglyphs folds Cyrillic letters looking like Latin ones, applied after case folding
+var homoglyphs = map[rune]rune{
+	'а': 'a', 'в': 'b', 'е': 'e', 'ё': 'e', 'к': 'k', 'м': 'm', 'н': 'h',
+	'о': 'o', 'р': 'p', 'с': 'c', 'т': 't', 'у': 'y', 'х': 'x',
+}
+
+// qualitySuffixes trailing name words describing stream quality rather than channel
+var qualitySuffixes = map[string]bool{
+	"hd": true, "fhd": true, "uhd": true, "sd": true, "4k": true, "8k": true,
+	"hevc": true, "h265": true, "50": true, "50fps": true, "orig": true, "original": true,
+}
+
+// nameWords splits name into case and homoglyph folded words, punctuation and spaces are separators
+func nameWords(name string) []string {
+	return strings.FieldsFunc(strings.Map(func(r rune) rune {
+		r = unicode.ToLower(r)
+		if folded, ok := homoglyphs[r]; ok {
+			return folded
+		}
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			return ' '
+		}
+		return r
+	}, name), unicode.IsSpace)
+}
+
+// splitName returns folded base name and quality suffix
+func splitName(name string) (string, string) {
+	words := nameWords(name)
+	base := len(words)
+	for base > 1 && qualitySuffixes[words[base-1]] {
+		base--
+	}
+	return strings.Join(words[:base], ""), strings.Join(words[base:], "")
+}
+
+// Aliases maps folded base names of provider spellings to canonical base names
+type Aliases struct {
+	names map[string]string
+}
+
+func NewAliases() *Aliases {
+	return &Aliases{names: map[string]string{}}
+}
+
+// Add registers alias spellings of canonical channel name, quality suffixes are ignored
+func (a *Aliases) Add(name string, aliases ...string) {
+	canonical, _ := splitName(name)
+	for _, alias := range aliases {
+		base, _ := splitName(alias)
+		if base != "" && base != canonical {
+			a.names[base] = canonical
+		}
+	}
+}
+
+func (a *Aliases) resolve(base string) string {
+	if a == nil {
+		return base
+	}
+	if canonical, ok := a.names[base]; ok {
+		return canonical
+	}
+	return base
+}
+
+// NormalizeName folds case, homoglyphs, spaces and punctuation, quality suffix is kept: "TV 1000 HD" -> "tv1000hd"
+func (a *Aliases) NormalizeName(name string) string {
+	base, quality := splitName(name)
+	return a.resolve(base) + quality
+}
+
+// CanonicalName normalized name without quality suffix: "ТВ1000 FHD" -> "tb1000"
+func (a *Aliases) CanonicalName(name string) string {
+	base, _ := splitName(name)
+	return a.resolve(base)
+}
+
+var channelAliases *Aliases
+var channelAliasesMutex sync.RWMutex
+
+// SetAliases replaces alias table used by name matching
+func SetAliases(aliases *Aliases) {
+	channelAliasesMutex.Lock()
+	defer channelAliasesMutex.Unlock()
+	channelAliases = aliases
+}
+
+func getAliases() *Aliases {
+	channelAliasesMutex.RLock()
+	defer channelAliasesMutex.RUnlock()
+	return channelAliases
+}
+
+// LoadAliases sets alias table from config and DB, DB errors are logged
+func LoadAliases() {
+	aliases := NewAliases()
+	for name, spellings := range cfg.GetAliases() {
+		aliases.Add(name, spellings...)
+	}
+
+	dbAliases, err := db.QueryGetChannelAliases()
+	if err != nil {
+		log.Printf("Failed to load channel aliases from DB: %v", err)
+	}
+	for alias, name := range dbAliases {
+		aliases.Add(name, alias)
+	}
+	SetAliases(aliases)
+}
+
+func NormalizeName(name string) string {
+	return getAliases().NormalizeName(name)
+}
+
+func CanonicalName(name string) string {
+	return getAliases().CanonicalName(name)
+}
+
+// matchChannels indexes of channels matching name, normalized names are matched first,
+// canonical names without quality suffix are used only if there is no normalized match
+func matchChannels(channels []*Channel, name string) []int {
+	var result []int
+	normalized := NormalizeName(name)
+	for i, channel := range channels {
+		if channel != nil && NormalizeName(channel.Name) == normalized {
+			result = append(result, i)
+		}
+	}
+	if len(result) != 0 {
+		return result
+	}
+
+	canonical := CanonicalName(name)
+	for i, channel := range channels {
+		if channel != nil && CanonicalName(channel.Name) == canonical {
+			result = append(result, i)
+		}
+	}
+	return result
+}
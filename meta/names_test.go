@@ -0,0 +1,60 @@
+package meta
+
+import "testing"
+
+func TestNormalizeName(t *testing.T) {
+	same := [][]string{
+		{"TV1000 HD", "TV 1000 HD", "tv-1000 hd"},
+		{"Страх HD", "СТРАХ HD", "Cтpax  HD"},
+		{"KLI Club FHD", "KLI Club FHD"},
+	}
+	for _, names := range same {
+		for _, name := range names[1:] {
+			if NormalizeName(name) != NormalizeName(names[0]) {
+				t.Fatalf("%s and %s must have same normalized name: %s, %s", names[0], name, NormalizeName(names[0]), NormalizeName(name))
+			}
+		}
+	}
+	if NormalizeName("Кино HD") == NormalizeName("Кино") {
+		t.Fatalf("quality suffix must be kept by NormalizeName")
+	}
+	if CanonicalName("Кино FHD 50") != CanonicalName("кино") || CanonicalName("HD") != "hd" {
+		t.Fatalf("unexpected canonical names: %s, %s", CanonicalName("Кино FHD 50"), CanonicalName("HD"))
+	}
+}
+
+func TestAliases(t *testing.T) {
+	aliases := NewAliases()
+	aliases.Add("Первый канал", "Первый", "Channel One")
+	SetAliases(aliases)
+	defer SetAliases(nil)
+
+	if NormalizeName("Channel One HD") != NormalizeName("Первый канал HD") || CanonicalName("ПЕРВЫЙ orig") != CanonicalName("Первый канал") {
+		t.Fatalf("aliases must resolve to canonical name")
+	}
+
+	group := &Group{Name: "HD", Channels: []*Channel{{Name: "Первый HD"}, {Name: "Channel One"}}}
+	if _, i := group.FindChannel("первый канал"); i != 1 {
+		t.Fatalf("normalized match must win over canonical one, got %d", i)
+	}
+	if _, i := group.FindChannel("Первый канал FHD"); i != 0 {
+		t.Fatalf("canonical match expected, got %d", i)
+	}
+
+	media := &Media{Groups: []*Group{group, {Name: "кино", Channels: []*Channel{{Name: "TV 1000 HD"}, {Name: "TV1000"}}}}}
+	media.forceChannels("HD", []string{"TV1000 HD"}, true)
+	if len(group.Channels) != 3 || group.Channels[2].Name != "TV 1000 HD" || len(media.Groups[1].Channels) != 1 {
+		t.Fatalf("forced channel must be matched by normalized name: %+v", group.Channels)
+	}
+
+	// SD stream is not forced into HD list unless other qualities are allowed
+	media = &Media{Groups: []*Group{{Name: "HD"}, {Name: "кино", Channels: []*Channel{{Name: "TV1000"}}}}}
+	media.forceChannels("HD", []string{"TV1000 HD"}, false)
+	if len(media.Groups[0].Channels) != 0 {
+		t.Fatalf("other quality must not be forced by default: %+v", media.Groups[0].Channels)
+	}
+	media.forceChannels("HD", []string{"TV1000 HD"}, true)
+	if len(media.Groups[0].Channels) != 1 {
+		t.Fatalf("other quality must be forced with any quality: %+v", media.Groups)
+	}
+}
@@ -0,0 +1,185 @@
+package meta
+
+import (
+	log "github.com/sirupsen/logrus"
+	"m3u8/cfg"
+	"m3u8/db"
+	"time"
+)
+
+// Numbering assigns stable channel numbers of output scope from group ranges.
+// Numbers are kept by normalized channel name, canonical name takes over number of renamed channel,
+// new channels get lowest free number of their group range in output order.
+// Numbers of channels not written for ReleaseAfter are released for new channels, zero keeps them forever.
+type Numbering struct {
+	ReleaseAfter time.Duration
+
+	scope  string
+	ranges map[string]cfg.NumberRange
+	stored []db.ChannelNumber
+	now    func() time.Time
+}
+
+func NewNumbering(scope string, ranges map[string]cfg.NumberRange, stored []db.ChannelNumber) *Numbering {
+	return &Numbering{scope: scope, ranges: ranges, stored: stored}
+}
+
+// LoadNumbering reads stored numbers of scope, DB errors are logged
+func LoadNumbering(scope string) *Numbering {
+	stored, err := db.QueryGetChannelNumbers(scope)
+	if err != nil {
+		log.Printf("Failed to load channel numbers of %s: %v", scope, err)
+	}
+	n := NewNumbering(scope, cfg.GetNumberRanges(), stored)
+	n.ReleaseAfter = time.Duration(cfg.GetNumberReleaseDays()) * 24 * time.Hour
+	return n
+}
+
+func (n *Numbering) getNow() time.Time {
+	if n.now == nil {
+		return time.Now()
+	}
+	return n.now()
+}
+
+// expired true if stored number was not written for release period
+func (n *Numbering) expired(stored db.ChannelNumber, now time.Time) bool {
+	return n.ReleaseAfter > 0 && now.Sub(stored.UpdatedAt) > n.ReleaseAfter
+}
+
+// numberedChannel channel of group numbers are assigned to
+type numberedChannel struct {
+	groupName string
+	channel   *Channel
+	number    int
+}
+
+// assign sets numbers of channels, returns changed assignments to store and keys of kept ones.
+// Expired numbers are kept by their channels but are free for new channels.
+func (n *Numbering) assign(channels []*numberedChannel) ([]db.ChannelNumber, []string) {
+	now := n.getNow()
+	taken := map[int]bool{}
+	byKey := map[string]int{}
+	byCanonical := map[string][]int{}
+	for i, stored := range n.stored {
+		if !n.expired(stored, now) {
+			taken[stored.Number] = true
+		}
+		byKey[stored.Key] = i
+		byCanonical[stored.Canonical] = append(byCanonical[stored.Canonical], i)
+	}
+
+	claimed := map[int]bool{}
+	numbers := map[string]int{}
+	var changed []db.ChannelNumber
+	var kept []string
+	claim := func(c *numberedChannel, key string, i int) {
+		claimed[i] = true
+		c.number = n.stored[i].Number
+		taken[c.number] = true
+		numbers[key] = c.number
+		if n.stored[i].Key != key {
+			changed = append(changed, db.ChannelNumber{Key: key, Canonical: CanonicalName(c.channel.Name), Number: c.number, UpdatedAt: now})
+		} else {
+			kept = append(kept, key)
+		}
+	}
+
+	// Stored numbers of same channel first, then of renamed channel of same canonical name
+	for pass := 0; pass < 3; pass++ {
+		for _, c := range channels {
+			r, ok := n.ranges[c.groupName]
+			if !ok || c.number != 0 {
+				continue
+			}
+			key := NormalizeName(c.channel.Name)
+			if number, ok := numbers[key]; ok {
+				// Duplicates of same name share number
+				c.number = number
+				continue
+			}
+			switch pass {
+			case 0:
+				if i, ok := byKey[key]; ok && !claimed[i] && r.Contains(n.stored[i].Number) {
+					claim(c, key, i)
+				}
+			case 1:
+				for _, i := range byCanonical[CanonicalName(c.channel.Name)] {
+					if !claimed[i] && r.Contains(n.stored[i].Number) {
+						if _, ok := numbers[n.stored[i].Key]; !ok {
+							claim(c, key, i)
+							break
+						}
+					}
+				}
+			case 2:
+				// Numbers released by this run are kept reserved till they expire
+				for number := r.From; number <= r.To; number++ {
+					if !taken[number] {
+						taken[number] = true
+						c.number = number
+						numbers[key] = number
+						changed = append(changed, db.ChannelNumber{Key: key, Canonical: CanonicalName(c.channel.Name), Number: number, UpdatedAt: now})
+						break
+					}
+				}
+				if c.number == 0 {
+					log.Warnf("No free channel number of group %s for %s in %s", c.groupName, c.channel.Name, n.scope)
+				}
+			}
+		}
+	}
+	return changed, kept
+}
+
+// store saves changed assignments, refreshes kept ones and deletes expired ones, stops at first DB error
+func (n *Numbering) store(changed []db.ChannelNumber, kept []string) {
+	now := n.getNow()
+	keptKeys := map[string]bool{}
+	for _, key := range kept {
+		keptKeys[key] = true
+	}
+	stored := make([]db.ChannelNumber, 0, len(n.stored))
+	for _, number := range mergeNumbers(n.stored, changed) {
+		if keptKeys[number.Key] {
+			number.UpdatedAt = now
+		}
+		if !n.expired(number, now) {
+			stored = append(stored, number)
+		}
+	}
+	n.stored = stored
+
+	for _, number := range changed {
+		if err := db.QuerySetChannelNumber(n.scope, number); err != nil {
+			log.Printf("Failed to store channel numbers of %s: %v", n.scope, err)
+			return
+		}
+	}
+	if err := db.QueryTouchChannelNumbers(n.scope, kept); err != nil {
+		log.Printf("Failed to refresh channel numbers of %s: %v", n.scope, err)
+		return
+	}
+	if n.ReleaseAfter > 0 {
+		if err := db.QueryReleaseChannelNumbers(n.scope, now.Add(-n.ReleaseAfter)); err != nil {
+			log.Printf("Failed to release channel numbers of %s: %v", n.scope, err)
+		}
+	}
+}
+
+// mergeNumbers replaces stored numbers of same key or number by changed ones
+func mergeNumbers(stored []db.ChannelNumber, changed []db.ChannelNumber) []db.ChannelNumber {
+	keys := map[string]bool{}
+	numbers := map[int]bool{}
+	for _, number := range changed {
+		keys[number.Key] = true
+		numbers[number.Number] = true
+	}
+	result := make([]db.ChannelNumber, 0, len(stored)+len(changed))
+	for _, number := range stored {
+		if !keys[number.Key] && !numbers[number.Number] {
+			result = append(result, number)
+		}
+	}
+	return append(result, changed...)
+}
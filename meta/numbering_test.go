@@ -0,0 +1,99 @@
+package meta
+
+import (
+	"bytes"
+	"m3u8/cfg"
+	"m3u8/db"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNumbering(t *testing.T) {
+	ranges := map[string]cfg.NumberRange{"HD": {From: 1, To: 99}, "кино": {From: 100, To: 102}}
+	stored := []db.ChannelNumber{
+		{Key: NormalizeName("Первый HD"), Canonical: CanonicalName("Первый HD"), Number: 1},
+		{Key: NormalizeName("Кино HD"), Canonical: CanonicalName("Кино HD"), Number: 100},
+		{Key: NormalizeName("Ушедший"), Canonical: CanonicalName("Ушедший"), Number: 2},
+		{Key: NormalizeName("Мульт"), Canonical: CanonicalName("Мульт"), Number: 101},
+	}
+	numbering := NewNumbering("test", ranges, stored)
+
+	channels := []*Channel{
+		{Name: "Новый HD", Url: "http://a/1"},
+		{Name: "ПЕРВЫЙ hd", Url: "http://a/2"},
+		{Name: "Кино FHD", Url: "http://a/3"},
+		{Name: "Мульт", Url: "http://a/4"},
+		{Name: "Другое", Url: "http://a/5"},
+		{Name: "Триллер", Url: "http://a/6"},
+	}
+	media := &Media{Groups: []*Group{
+		{Name: "HD", Channels: channels[:2]},
+		{Name: "кино", Channels: []*Channel{channels[2], channels[5]}},
+		// Channel moved out of its range gets new number
+		{Name: "HD", Channels: []*Channel{channels[3]}},
+		{Name: "другие", Channels: []*Channel{channels[4]}},
+	}}
+
+	var buf bytes.Buffer
+	if err := media.writeView(&buf, &outputView{output: &cfg.Output{}}, "", numbering); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	expected := []string{`tvg-chno="3",Новый HD`, `tvg-chno="1",ПЕРВЫЙ hd`, `tvg-chno="100",Кино FHD`, `tvg-chno="102",Триллер`, `tvg-chno="4",Мульт`}
+	for _, item := range expected {
+		if !strings.Contains(buf.String(), item) {
+			t.Fatalf("%s is missing:\n%s", item, buf.String())
+		}
+	}
+	if strings.Count(buf.String(), "tvg-chno") != len(expected) {
+		t.Fatalf("channels of groups without range must have no number:\n%s", buf.String())
+	}
+
+	// Numbers survive reorder
+	media.Groups[0].Channels = []*Channel{channels[1], channels[0]}
+	media.Groups[1], media.Groups[2] = media.Groups[2], media.Groups[1]
+	buf.Reset()
+	if err := media.writeView(&buf, &outputView{output: &cfg.Output{}}, "", numbering); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	for _, item := range expected {
+		if !strings.Contains(buf.String(), item) {
+			t.Fatalf("%s is missing after reorder:\n%s", item, buf.String())
+		}
+	}
+}
+
+func TestNumberingRelease(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	stored := []db.ChannelNumber{
+		{Key: NormalizeName("Старый"), Canonical: CanonicalName("Старый"), Number: 1, UpdatedAt: now.AddDate(0, 0, -20)},
+		{Key: NormalizeName("Ушедший"), Canonical: CanonicalName("Ушедший"), Number: 2, UpdatedAt: now.AddDate(0, 0, -40)},
+		{Key: NormalizeName("Вернувшийся"), Canonical: CanonicalName("Вернувшийся"), Number: 3, UpdatedAt: now.AddDate(0, 0, -40)},
+	}
+	numbering := NewNumbering("test", map[string]cfg.NumberRange{"HD": {From: 1, To: 3}}, stored)
+	numbering.ReleaseAfter = 30 * 24 * time.Hour
+	numbering.now = func() time.Time { return now }
+
+	channels := []*numberedChannel{
+		{groupName: "HD", channel: &Channel{Name: "Новый"}},
+		{groupName: "HD", channel: &Channel{Name: "Старый"}},
+		{groupName: "HD", channel: &Channel{Name: "Вернувшийся"}},
+	}
+	changed, kept := numbering.assign(channels)
+	if channels[0].number != 2 || channels[1].number != 1 || channels[2].number != 3 {
+		t.Fatalf("new channel must get expired number: %d, %d, %d", channels[0].number, channels[1].number, channels[2].number)
+	}
+	if len(changed) != 1 || len(kept) != 2 {
+		t.Fatalf("unexpected changes %+v, kept %v", changed, kept)
+	}
+
+	numbering.store(changed, kept)
+	for _, number := range numbering.stored {
+		if !number.UpdatedAt.Equal(now) {
+			t.Fatalf("written numbers must be refreshed: %+v", numbering.stored)
+		}
+	}
+	if len(numbering.stored) != 3 {
+		t.Fatalf("expired number of missing channel must be released: %+v", numbering.stored)
+	}
+}
@@ -0,0 +1,98 @@
+package meta
+
+import (
+	log "github.com/sirupsen/logrus"
+	"m3u8/cfg"
+	"m3u8/xmltv"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Parental decides which channels are censored
+type Parental struct {
+	groups   []string
+	channels []*regexp.Regexp
+	minAge   int
+	minShare float64
+	// ratings EPG airtime by age rating of tvg id or tvg name
+	ratings *xmltv.Ratings
+}
+
+// NewParental builds parental rules from config and EPG ratings
+func NewParental(conf cfg.Parental, ratings *xmltv.Ratings) (*Parental, error) {
+	channels, err := compileRegexps(conf.Channels)
+	if err != nil {
+		return nil, err
+	}
+	return &Parental{groups: conf.Groups, channels: channels, minAge: conf.MinAge, minShare: float64(conf.MinShare) / 100, ratings: ratings}, nil
+}
+
+// IsCensored true if channel group name contains censored group, channel matches censored name or
+// its EPG airtime share rated at least min age reaches min share
+func (p *Parental) IsCensored(c *Channel, groupName string) bool {
+	if p == nil {
+		return false
+	}
+	if p.censoredGroup(groupName) || matchAny(p.channels, c.Name) {
+		return true
+	}
+	if p.minAge <= 0 {
+		return false
+	}
+	ratings := p.ratings.Channel(c.Info.TvgId, c.TvgName, c.Info.TvgName)
+	return ratings != nil && ratings.Share(p.minAge) >= p.minShare
+}
+
+// censoredGroup case insensitive substring match: "взрослые" censors "Взрослые HD" too
+func (p *Parental) censoredGroup(groupName string) bool {
+	groupName = strings.ToLower(groupName)
+	for _, group := range p.groups {
+		if group != "" && strings.Contains(groupName, strings.ToLower(group)) {
+			return true
+		}
+	}
+	return false
+}
+
+var defaultParental = &Parental{groups: []string{"взрослые"}}
+
+var parental = defaultParental
+var parentalMutex sync.RWMutex
+
+// SetParental replaces parental rules used by outputs, nil restores default censored group
+func SetParental(p *Parental) {
+	parentalMutex.Lock()
+	defer parentalMutex.Unlock()
+	if p == nil {
+		p = defaultParental
+	}
+	parental = p
+}
+
+func getParental() *Parental {
+	parentalMutex.RLock()
+	defer parentalMutex.RUnlock()
+	return parental
+}
+
+// LoadParental sets parental rules from config, EPG ratings are read from guide file if min age is set;
+// broken rules keep default ones
+func LoadParental() {
+	conf := cfg.GetParental()
+	var ratings *xmltv.Ratings
+	if conf.MinAge > 0 && conf.EpgFile != "" {
+		var err error
+		ratings, err = xmltv.LoadRatings(conf.EpgFile)
+		if err != nil {
+			log.Printf("Failed to load EPG ratings: %v", err)
+		}
+	}
+
+	p, err := NewParental(conf, ratings)
+	if err != nil {
+		log.Errorf("invalid parental config: %v", err)
+		return
+	}
+	SetParental(p)
+}
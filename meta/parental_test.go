@@ -0,0 +1,96 @@
+package meta
+
+import (
+	"bytes"
+	"m3u8/cfg"
+	"m3u8/xmltv"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParental(t *testing.T) {
+	guide := filepath.Join(t.TempDir(), "epg.xml")
+	err := os.WriteFile(guide, []byte(`<?xml version="1.0" encoding="utf-8"?>
+<tv>
+<channel id="1"><display-name>Кино ТВ</display-name></channel>
+<channel id="2"><display-name>Мульт</display-name></channel>
+<programme start="20261018120000 +0300" stop="20261018140000 +0300" channel="1"><title>A</title><rating system="RU"><value>18+</value></rating></programme>
+<programme start="20261018140000 +0300" stop="20261018150000 +0300" channel="1"><title>News</title></programme>
+<programme start="20261018120000 +0300" stop="20261018140000 +0300" channel="2"><title>B</title><rating system="MPAA"><value>PG</value></rating></programme>
+<programme start="20261018230000 +0300" stop="20261019000000 +0300" channel="2"><title>Night film</title><rating system="RU"><value>18+</value></rating></programme>
+<channel id="night"><display-name>Ночное кино</display-name></channel>
+<programme start="20261018000000 +0300" stop="20261018040000 +0300" channel="night"><title>C</title><rating system="RU"><value>18+</value></rating></programme>
+</tv>`), 0644)
+	if err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	ratings, err := xmltv.LoadRatings(guide)
+	if err != nil || ratings.Channel("", "Кино ТВ").Share(18) < 0.66 || ratings.Channel("2").Share(18) > 0.34 || ratings.Channel("", "Мульт").Share(10) != 1 {
+		t.Fatalf("unexpected ratings %v: %v", ratings, err)
+	}
+	broken := filepath.Join(t.TempDir(), "broken.xml")
+	if err = os.WriteFile(broken, []byte(`<tv><channel id="1"><display-name>A</display-name></channel><programme`), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if _, err = xmltv.LoadRatings(broken); err == nil {
+		t.Fatalf("malformed guide must fail")
+	}
+
+	// Single 18+ film of mostly general channel keeps it uncensored
+	p, err := NewParental(cfg.Parental{Groups: []string{"Adult"}, Channels: []string{"(?i)playboy"}, MinAge: 18, MinShare: 50}, ratings)
+	if err != nil {
+		t.Fatalf("NewParental failed: %v", err)
+	}
+	SetParental(p)
+	defer SetParental(nil)
+
+	channels := []*Channel{
+		{Name: "Кино", TvgName: "Кино ТВ", Url: "http://a/1"},
+		{Name: "Мульт", TvgName: "Мульт", Url: "http://a/2"},
+		{Name: "Playboy TV", Url: "http://a/3"},
+		{Name: "Brazzers", Url: "http://a/4"},
+	}
+	media := &Media{Groups: []*Group{
+		{Name: "кино", Channels: channels[:3]},
+		{Name: "adult", Channels: channels[3:]},
+	}}
+	write := func(mode string) string {
+		var buf bytes.Buffer
+		if err := media.write(&buf, &cfg.Output{CensoredMode: mode, CensoredGroup: "PIN"}, ""); err != nil {
+			t.Fatalf("write failed: %v", err)
+		}
+		return buf.String()
+	}
+
+	if result := write(cfg.CensoredModeMark); strings.Count(result, `censored="1"`) != 3 || strings.Contains(result, `censored="1",Мульт`) {
+		t.Fatalf("unexpected marked output:\n%s", result)
+	}
+	if result := write(cfg.CensoredModeDrop); strings.Count(result, "http://") != 1 || !strings.Contains(result, "http://a/2") {
+		t.Fatalf("unexpected dropped output:\n%s", result)
+	}
+	result := write(cfg.CensoredModeMove)
+	if !strings.HasSuffix(result, "#EXTGRP:PIN\nhttp://a/1\n"+
+		"#EXTINF:0 tvg-rec=\"0\" catchup=\"shift\" catchup-days=\"0\" censored=\"1\",Playboy TV\n#EXTGRP:PIN\nhttp://a/3\n"+
+		"#EXTINF:0 tvg-rec=\"0\" catchup=\"shift\" catchup-days=\"0\" censored=\"1\",Brazzers\n#EXTGRP:PIN\nhttp://a/4\n") {
+		t.Fatalf("unexpected moved output:\n%s", result)
+	}
+
+	if !p.IsCensored(&Channel{Name: "Late", Info: ChannelInfo{TvgId: "night"}}, "кино") {
+		t.Fatalf("guide ratings must be found by tvg-id")
+	}
+	if !p.IsCensored(&Channel{}, "adult HD") {
+		t.Fatalf("group containing censored group must be censored")
+	}
+
+	SetParental(nil)
+	for _, group := range []string{"взрослые", "Взрослые HD", "взрослые 18+"} {
+		if !getParental().IsCensored(&Channel{}, group) {
+			t.Fatalf("default parental must censor %s group", group)
+		}
+	}
+	if getParental().IsCensored(channels[2], "кино") {
+		t.Fatalf("default parental must censor only взрослые groups")
+	}
+}
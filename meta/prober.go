@@ -0,0 +1,128 @@
+package meta
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"m3u8/cfg"
+	"m3u8/ffprobe"
+	"m3u8/tsprobe"
+	"time"
+)
+
+const (
+	ProberFFProbe = "ffprobe"
+	ProberTS      = "tsprobe"
+)
+
+var ErrNoVideo = errors.New("no video stream with dimensions")
+
+// Prober loads stream meta data, remoteId identifies channel for caching
+type Prober interface {
+	Probe(ctx context.Context, remoteId string, streamUrl string) (*ffprobe.MetaData, error)
+}
+
+// DefaultProber reads playlists and probes streams with configured prober
+func DefaultProber() Prober {
+	var stream Prober = &ffprobe.Prober{}
+	if cfg.GetProber() == ProberTS {
+		stream = FallbackProber{&tsprobe.Prober{MaxBytes: int64(cfg.GetProbeBytes())}, stream}
+	}
+	return &ManifestProber{Next: stream}
+}
+
+func hasVideo(metaData *ffprobe.MetaData) bool {
+	if metaData == nil {
+		return false
+	}
+	vidStream := metaData.GetVideoStream()
+	return vidStream != nil && vidStream.Width != 0 && vidStream.Height != 0
+}
+
+// FallbackProber tries probers in order till video dimensions are found
+type FallbackProber []Prober
+
+func (f FallbackProber) Probe(ctx context.Context, remoteId string, streamUrl string) (*ffprobe.MetaData, error) {
+	err := ErrNoVideo
+	for i, prober := range f {
+		var metaData *ffprobe.MetaData
+		metaData, err = prober.Probe(ctx, remoteId, streamUrl)
+		if err == nil && hasVideo(metaData) {
+			return metaData, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if err == nil {
+			err = ErrNoVideo
+		}
+		if i < len(f)-1 {
+			log.Printf("Probe of %s failed, trying next prober: %v", streamUrl, err)
+		}
+	}
+	return nil, err
+}
+
+// ManifestProber takes meta from master playlist variants,
+// segments and non playlist urls are probed with Next prober, manifest only if Next is nil
+type ManifestProber struct {
+	Next Prober
+	// Timeout playlist download timeout
+	Timeout time.Duration
+}
+
+func (p *ManifestProber) Probe(ctx context.Context, remoteId string, streamUrl string) (*ffprobe.MetaData, error) {
+	media, _, err := OpenContext(ctx, streamUrl, &ReadOptions{Timeout: p.Timeout})
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	if err != nil {
+		// Live stream without playlist
+		return p.next(ctx, remoteId, streamUrl)
+	}
+
+	if media.IsMaster() {
+		variant := media.SelectVariant(VariantHighest, 0)
+		if variant == nil {
+			return nil, fmt.Errorf("master playlist %s has no variants", streamUrl)
+		}
+		if variant.HasAllMeta() {
+			// Manifest already describes stream, no need to probe it
+			return variant.MetaData(), nil
+		}
+		variantUrl := media.ResolveUrl(variant.Uri)
+		media, _, err = OpenContext(ctx, variantUrl, &ReadOptions{Timeout: p.Timeout})
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if err != nil || len(media.Records) == 0 {
+			return p.next(ctx, remoteId, variantUrl)
+		}
+	}
+
+	if len(media.Records) == 0 {
+		return nil, fmt.Errorf("playlist %s has no segments", streamUrl)
+	}
+
+	// Last segments are the most likely to be still available
+	err = ErrNoVideo
+	for i := len(media.Records) - 1; i >= 0; i-- {
+		var metaData *ffprobe.MetaData
+		metaData, err = p.next(ctx, remoteId, media.ResolveUrl(media.Records[i].Url))
+		if err == nil && hasVideo(metaData) {
+			return metaData, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+	}
+	return nil, err
+}
+
+func (p *ManifestProber) next(ctx context.Context, remoteId string, streamUrl string) (*ffprobe.MetaData, error) {
+	if p.Next == nil {
+		return nil, fmt.Errorf("%s has no manifest meta", streamUrl)
+	}
+	return p.Next.Probe(ctx, remoteId, streamUrl)
+}
@@ -0,0 +1,97 @@
+package meta
+
+import (
+	"context"
+	"fmt"
+	"m3u8/ffprobe"
+	"sync"
+)
+
+// FakeResult scripted FakeProber response
+type FakeResult struct {
+	MetaData *ffprobe.MetaData
+	Err      error
+}
+
+// FakeProber returns scripted results by url and records probed urls, for tests
+type FakeProber struct {
+	Results map[string]FakeResult
+
+	calls  []string
+	checks []string
+	mutex  sync.Mutex
+}
+
+func NewFakeProber() *FakeProber {
+	return &FakeProber{Results: map[string]FakeResult{}}
+}
+
+// FakeMetaData meta data of single video stream
+func FakeMetaData(width int, height int, frameRate int) *ffprobe.MetaData {
+	return &ffprobe.MetaData{
+		Streams: []ffprobe.StreamData{{
+			CodecType:    "video",
+			Width:        width,
+			Height:       height,
+			RFrameRate:   ffprobe.NewFraction(frameRate, 1),
+			AVGFrameRate: ffprobe.NewFraction(frameRate, 1),
+		}},
+	}
+}
+
+// Add scripts successful probe of url
+func (f *FakeProber) Add(streamUrl string, metaData *ffprobe.MetaData) *FakeProber {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.Results[streamUrl] = FakeResult{MetaData: metaData}
+	return f
+}
+
+// Fail scripts failed probe of url
+func (f *FakeProber) Fail(streamUrl string, err error) *FakeProber {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.Results[streamUrl] = FakeResult{Err: err}
+	return f
+}
+
+// Calls probed urls in call order
+func (f *FakeProber) Calls() []string {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return append([]string{}, f.calls...)
+}
+
+// Check scripted availability of url, urls without failure are available
+func (f *FakeProber) Check(ctx context.Context, streamUrl string) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.checks = append(f.checks, streamUrl)
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return f.Results[streamUrl].Err
+}
+
+// Checks health checked urls in call order
+func (f *FakeProber) Checks() []string {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return append([]string{}, f.checks...)
+}
+
+func (f *FakeProber) Probe(ctx context.Context, remoteId string, streamUrl string) (*ffprobe.MetaData, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.calls = append(f.calls, streamUrl)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	result, ok := f.Results[streamUrl]
+	if !ok {
+		return nil, fmt.Errorf("unexpected probe of %s", streamUrl)
+	}
+	return result.MetaData, result.Err
+}
@@ -0,0 +1,139 @@
+package meta
+
+import (
+	"context"
+	"errors"
+	"m3u8/ffprobe"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestManifestProber(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"full.m3u8": "#EXTM3U\n" +
+			"#EXT-X-STREAM-INF:BANDWIDTH=800000,RESOLUTION=640x360,FRAME-RATE=25.000\nlow.m3u8\n" +
+			"#EXT-X-STREAM-INF:BANDWIDTH=5000000,RESOLUTION=1920x1080,FRAME-RATE=50.000\nhigh.m3u8\n",
+		"partial.m3u8": "#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=5000000\nhigh.m3u8\n",
+		"high.m3u8":    "#EXTM3U\n#EXT-X-TARGETDURATION:10\n#EXTINF:10,\nseg1.ts\n#EXTINF:10,\nseg2.ts\n",
+		"live.ts":      "\x47\x40\x00\x10",
+	}
+	for name, content := range files {
+		err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644)
+		if err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+	location := func(name string) string {
+		return "file://" + filepath.ToSlash(filepath.Join(dir, name))
+	}
+
+	fake := NewFakeProber()
+	prober := &ManifestProber{Next: fake}
+	ctx := context.Background()
+
+	metaData, err := prober.Probe(ctx, "1", location("full.m3u8"))
+	if err != nil || !hasVideo(metaData) || len(fake.Calls()) != 0 {
+		t.Fatalf("master playlist meta must be used without probing: %v, %v", err, fake.Calls())
+	}
+	if w, h := metaData.GetDimension(); w != 1920 || h != 1080 {
+		t.Fatalf("unexpected dimension %dx%d", w, h)
+	}
+
+	// Last segment fails, previous one is probed
+	fake.Fail(location("seg2.ts"), errors.New("gone")).Add(location("seg1.ts"), FakeMetaData(1280, 720, 25))
+	metaData, err = prober.Probe(ctx, "2", location("partial.m3u8"))
+	if err != nil || metaData.GetVideoStream().Height != 720 {
+		t.Fatalf("segment probe failed: %v", err)
+	}
+	calls := fake.Calls()
+	if len(calls) != 2 || calls[0] != location("seg2.ts") || calls[1] != location("seg1.ts") {
+		t.Fatalf("unexpected probe calls: %v", calls)
+	}
+
+	fake.Add(location("live.ts"), FakeMetaData(720, 576, 25))
+	metaData, err = prober.Probe(ctx, "3", location("live.ts"))
+	if err != nil || metaData.GetVideoStream().Width != 720 {
+		t.Fatalf("live stream probe failed: %v", err)
+	}
+
+	_, err = (&ManifestProber{}).Probe(ctx, "3", location("live.ts"))
+	if err == nil {
+		t.Fatalf("manifest only prober must fail without manifest meta")
+	}
+}
+
+func TestManifestProberCancel(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	fake := NewFakeProber()
+	start := time.Now()
+	_, err := (&ManifestProber{Next: fake, Timeout: 10 * time.Second}).Probe(ctx, "1", server.URL+"/index.m3u8")
+	if !errors.Is(err, context.DeadlineExceeded) || time.Since(start) > 5*time.Second || len(fake.Calls()) != 0 {
+		t.Fatalf("playlist download must stop with context: %v, %v", err, fake.Calls())
+	}
+}
+
+func TestChannelLoadMeta(t *testing.T) {
+	fake := NewFakeProber().Add("http://host/iptv/key/101/index.m3u8", FakeMetaData(1920, 1080, 50))
+	fallback := FallbackProber{NewFakeProber(), fake}
+
+	channel := &Channel{Url: "http://host/iptv/key/101/index.m3u8", prober: fallback}
+	channel.SetName(`0 tvg-rec="3",Kino HD`, "кино")
+	if channel.RemoteId != "101" || channel.Width != 1920 || channel.Height != 1080 || channel.FrameRate != 50 {
+		t.Fatalf("unexpected channel meta: %+v", channel)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	channel = &Channel{Url: "http://host/iptv/key/102/index.m3u8", prober: fallback}
+	if channel.loadMeta(ctx, "102") != nil || channel.Width != 0 {
+		t.Fatalf("canceled probe must not set meta")
+	}
+}
+
+func TestChannelStreamDetails(t *testing.T) {
+	metaData := FakeMetaData(720, 576, 25)
+	metaData.Streams[0].CodecName = "mpeg2video"
+	metaData.Streams[0].FieldOrder = "tt"
+	metaData.Streams = append(metaData.Streams,
+		ffprobe.StreamData{CodecType: "audio", CodecName: "mp2", Channels: 2, Tags: map[string]string{"language": "rus"}},
+		ffprobe.StreamData{CodecType: "audio", CodecName: "ac3", Channels: 6},
+		ffprobe.StreamData{CodecType: "subtitle", CodecName: "dvb_subtitle"},
+	)
+	metaData.Format.BitRate = "4000000"
+
+	channel := &Channel{}
+	if channel.applyMeta(metaData) == nil {
+		t.Fatalf("applyMeta failed")
+	}
+	if channel.VideoCodec != "mpeg2video" || !channel.IsInterlaced() || channel.BitRate != 4000000 || !channel.HasSubtitles {
+		t.Fatalf("unexpected stream details: %+v", channel)
+	}
+	if len(channel.AudioTracks) != 2 || channel.AudioTracks[0] != (AudioTrack{Codec: "mp2", Channels: 2, Language: "rus"}) || channel.AudioTracks[1].Channels != 6 {
+		t.Fatalf("unexpected audio tracks: %+v", channel.AudioTracks)
+	}
+
+	variant := newVariant(ParseAttributeList(`BANDWIDTH=5000000,AVERAGE-BANDWIDTH=4500000,RESOLUTION=1920x1080,FRAME-RATE=50,CODECS="hvc1.2.4.L120.B0,ec-3"`), false)
+	channel = &Channel{}
+	if channel.applyMeta(variant.MetaData()) == nil {
+		t.Fatalf("applyMeta of variant failed")
+	}
+	if channel.VideoCodec != "hevc" || channel.BitRate != 4500000 || channel.IsInterlaced() || len(channel.AudioTracks) != 1 || channel.AudioTracks[0].Codec != "eac3" {
+		t.Fatalf("unexpected variant details: %+v", channel)
+	}
+}
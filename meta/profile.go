@@ -0,0 +1,140 @@
+package meta
+
+import (
+	"errors"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"m3u8/cfg"
+	"m3u8/db"
+	"m3u8/util"
+	"net/url"
+	"regexp"
+	"sync"
+)
+
+// UrlProfile describes provider channel url layout with named regex groups:
+// remote_id (required), access_key, sub_domain, host
+type UrlProfile struct {
+	Name           string
+	Pattern        *regexp.Regexp
+	RemoteIdPrefix string
+}
+
+// DefaultUrlProfile http://wkejhfk.rossteleccom.net/iptv/ABCD3HG7DW38ZD/205/index.m3u8
+// host + / + "iptv" + / + key + / + channel_id + / + file, channel_id is last numeric path segment:
+// http://host/live/user/pass/123.ts has key user/pass and channel_id 123
+var DefaultUrlProfile = &UrlProfile{
+	Pattern: regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9+.-]*://(?:[^@/]*@)?(?:(?P<sub_domain>[^./:]+)\.)?(?P<host>[^/:?#]+)(?::\d+)?/[^/?#]+/(?P<access_key>[^?#]+)/(?P<remote_id>\d+)(?:\.[^/?#]*)?(?:/[^/?#]*)?(?:[?#].*)?$`),
+}
+
+var urlProfiles []*UrlProfile
+var urlProfilesLoaded bool
+var urlProfilesMutex sync.Mutex
+
+// ChannelUrl ids extracted from channel url
+type ChannelUrl struct {
+	RemoteId string
+	Provider db.Provider
+	Profile  *UrlProfile
+}
+
+func NewUrlProfile(name string, pattern string, remoteIdPrefix string) (*UrlProfile, error) {
+	reg, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	if reg.SubexpIndex("remote_id") < 0 {
+		return nil, errors.New("url pattern has no remote_id group")
+	}
+	return &UrlProfile{
+		Name:           name,
+		Pattern:        reg,
+		RemoteIdPrefix: remoteIdPrefix,
+	}, nil
+}
+
+// Parse extracts remote id and provider from url, returns nil if url doesn't match profile
+func (p *UrlProfile) Parse(rawUrl string) *ChannelUrl {
+	match := p.Pattern.FindStringSubmatch(rawUrl)
+	if match == nil {
+		return nil
+	}
+	group := func(name string) string {
+		i := p.Pattern.SubexpIndex(name)
+		if i < 0 {
+			return ""
+		}
+		return match[i]
+	}
+
+	remoteId := group("remote_id")
+	if remoteId == "" {
+		return nil
+	}
+
+	provider := db.Provider{
+		Name:      p.Name,
+		Host:      group("host"),
+		SubDomain: group("sub_domain"),
+		AccessKey: group("access_key"),
+	}
+	if provider.Host == "" {
+		u, err := url.Parse(rawUrl)
+		if err == nil {
+			provider.Host = u.Hostname()
+		}
+	}
+
+	return &ChannelUrl{
+		RemoteId: p.RemoteIdPrefix + remoteId,
+		Provider: provider,
+		Profile:  p,
+	}
+}
+
+// SetUrlProfiles overrides profiles from order config
+func SetUrlProfiles(profiles []*UrlProfile) {
+	urlProfilesMutex.Lock()
+	defer urlProfilesMutex.Unlock()
+
+	urlProfiles = profiles
+	urlProfilesLoaded = true
+}
+
+func getUrlProfiles() []*UrlProfile {
+	urlProfilesMutex.Lock()
+	defer urlProfilesMutex.Unlock()
+
+	if urlProfilesLoaded {
+		return urlProfiles
+	}
+	urlProfilesLoaded = true
+
+	for _, item := range cfg.GetProviders() {
+		switch item.(type) {
+		case map[string]interface{}:
+			conf := item.(map[string]interface{})
+			name := util.GetValue("name", conf, "")
+			profile, err := NewUrlProfile(name, util.GetValue("url_pattern", conf, ""), util.GetValue("remote_id_prefix", conf, ""))
+			if err != nil {
+				log.Errorf("invalid provider %s url profile: %v", name, err)
+				continue
+			}
+			urlProfiles = append(urlProfiles, profile)
+		}
+	}
+	return urlProfiles
+}
+
+// ParseChannelUrl extracts remote id and provider with first matching profile
+func ParseChannelUrl(rawUrl string) (*ChannelUrl, error) {
+	for _, profile := range getUrlProfiles() {
+		if channelUrl := profile.Parse(rawUrl); channelUrl != nil {
+			return channelUrl, nil
+		}
+	}
+	if channelUrl := DefaultUrlProfile.Parse(rawUrl); channelUrl != nil {
+		return channelUrl, nil
+	}
+	return nil, fmt.Errorf("no url profile matches %s", rawUrl)
+}
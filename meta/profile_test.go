@@ -0,0 +1,66 @@
+package meta
+
+import "testing"
+
+func TestParseChannelUrl(t *testing.T) {
+	xtream, err := NewUrlProfile("xtream", `^https?://(?P<host>[^/:]+)(?::\d+)?/live/(?P<access_key>[^/]+/[^/]+)/(?P<remote_id>\d+)`, "xtream:")
+	if err != nil {
+		t.Fatalf("NewUrlProfile failed: %v", err)
+	}
+	stalker, err := NewUrlProfile("stalker", `^https?://(?P<host>[^/:]+)(?::\d+)?/play/live\.php\?mac=(?P<access_key>[^&]+)&stream=(?P<remote_id>\d+)`, "stalker:")
+	if err != nil {
+		t.Fatalf("NewUrlProfile failed: %v", err)
+	}
+	_, err = NewUrlProfile("invalid", `^https?://(?P<host>[^/]+)/`, "")
+	if err == nil {
+		t.Fatalf("pattern without remote_id must be rejected")
+	}
+
+	SetUrlProfiles([]*UrlProfile{xtream, stalker})
+	defer SetUrlProfiles(nil)
+
+	tests := []struct {
+		url       string
+		remoteId  string
+		host      string
+		subDomain string
+		accessKey string
+	}{
+		{url: "http://wkejhfk.rossteleccom.net/iptv/ABCD3HG7DW38ZD/205/index.m3u8", remoteId: "205", host: "rossteleccom.net", subDomain: "wkejhfk", accessKey: "ABCD3HG7DW38ZD"},
+		{url: "http://panel.example.com:8080/live/user/pass/123.ts", remoteId: "xtream:123", host: "panel.example.com", accessKey: "user/pass"},
+		{url: "http://portal.example.com/play/live.php?mac=00:1A:79:00:00:01&stream=456&extension=ts", remoteId: "stalker:456", host: "portal.example.com", accessKey: "00:1A:79:00:00:01"},
+	}
+
+	for _, test := range tests {
+		channelUrl, err := ParseChannelUrl(test.url)
+		if err != nil {
+			t.Fatalf("ParseChannelUrl %s failed: %v", test.url, err)
+		}
+		p := channelUrl.Provider
+		if channelUrl.RemoteId != test.remoteId || p.Host != test.host || p.SubDomain != test.subDomain || p.AccessKey != test.accessKey {
+			t.Fatalf("unexpected result for %s: %+v", test.url, channelUrl)
+		}
+	}
+
+	_, err = ParseChannelUrl("http://host/index.m3u8")
+	if err == nil {
+		t.Fatalf("short url path must not match")
+	}
+
+	// Default profile takes last numeric path segment as remote id
+	SetUrlProfiles(nil)
+	for rawUrl, expected := range map[string][2]string{
+		"http://panel.example.com:8080/live/user/pass/123.ts": {"123", "user/pass"},
+		"http://panel.example.com/user/pass/123":              {"123", "pass"},
+		"http://a.host.net/iptv/KEY/205/index.m3u8?token=1":   {"205", "KEY"},
+		"http://a.host.net/iptv/KEY/205/mpegts":               {"205", "KEY"},
+	} {
+		channelUrl, err := ParseChannelUrl(rawUrl)
+		if err != nil || channelUrl.RemoteId != expected[0] || channelUrl.Provider.AccessKey != expected[1] {
+			t.Fatalf("unexpected default profile result for %s: %+v %v", rawUrl, channelUrl, err)
+		}
+	}
+	if _, err = ParseChannelUrl("http://host/iptv/KEY/news/index.m3u8"); err == nil {
+		t.Fatalf("url without numeric remote id must not match default profile")
+	}
+}
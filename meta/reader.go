@@ -0,0 +1,212 @@
+package meta
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"io"
+	"m3u8/util"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const StdinLocation = "-"
+
+type ReadOptions struct {
+	ForceReloadChannelData bool
+	NoSampleLoad           bool
+
+	// BaseUrl playlist location for relative uri resolving
+	BaseUrl string
+	// Timeout for remote playlist download
+	Timeout time.Duration
+	// Strict stops parsing at first error diagnostic
+	Strict bool
+	// Encoding forced source encoding, e.g. "windows-1251"
+	Encoding string
+	// ContentType source Content-Type header used for charset detection
+	ContentType string
+
+	// GroupSources group resolution precedence: GroupSourceExtGrp, GroupSourceGroupTitle
+	GroupSources []string
+	// FallbackGroup for records without any group, such records are skipped if empty
+	FallbackGroup string
+
+	// Prober loads channel stream meta, DefaultProber if nil
+	Prober Prober
+	// Freshness re-probe policy of stored meta, stored meta never expires if nil
+	Freshness *Freshness
+	// MetaCache probe results store used when DB has no fresh meta, disabled if nil
+	MetaCache *MetaCache
+	// HealthCheck requests streams of channels which meta is not probed so dead channels are detected
+	HealthCheck bool
+
+	// Source list name kept on channels as provenance, SourcePriority ranks it in merged outputs
+	Source         string
+	SourcePriority int
+}
+
+func (o *ReadOptions) getTimeout() time.Duration {
+	if o == nil || o.Timeout <= 0 {
+		return 10 * time.Second
+	}
+	return o.Timeout
+}
+
+// Parse reads playlist records without channels processing
+func Parse(r io.Reader, opts *ReadOptions) (*Media, *ParseReport, error) {
+	media, err := readRecords(r, opts)
+	return media, media.report, err
+}
+
+// Read parses playlist and structures channel records into groups
+func Read(r io.Reader, opts *ReadOptions) (*Media, *ParseReport, error) {
+	media, report, err := Parse(r, opts)
+	if err != nil {
+		return nil, report, err
+	}
+	media.structRecords()
+	return media, report, nil
+}
+
+// FromRecords builds media from records collected by other sources, e.g. provider api
+func FromRecords(records []*Record, opts *ReadOptions) *Media {
+	media := newMedia(opts)
+	media.validFileType = true
+	media.Records = records
+	media.structRecords()
+	return media
+}
+
+func newMedia(opts *ReadOptions) *Media {
+	media := Media{report: &ParseReport{}}
+	if opts != nil {
+		media.forceReloadChannelData = opts.ForceReloadChannelData
+		media.noSampleLoad = opts.NoSampleLoad
+		media.BaseUrl = opts.BaseUrl
+		media.strict = opts.Strict
+		media.groupSources = opts.GroupSources
+		media.fallbackGroup = opts.FallbackGroup
+		media.prober = opts.Prober
+		media.freshness = opts.Freshness
+		media.metaCache = opts.MetaCache
+		media.healthCheck = opts.HealthCheck
+		media.source = opts.Source
+		media.sourcePriority = opts.SourcePriority
+	}
+	return &media
+}
+
+// Open reads playlist from http(s) url, file:// url, local file path or stdin with "-"
+func Open(location string, opts *ReadOptions) (*Media, *ParseReport, error) {
+	return OpenContext(context.Background(), location, opts)
+}
+
+// OpenContext reads playlist like Open, http(s) download is cancelled with ctx
+func OpenContext(ctx context.Context, location string, opts *ReadOptions) (*Media, *ParseReport, error) {
+	reader, baseUrl, contentType, err := openLocation(ctx, location, opts.getTimeout())
+	if err != nil {
+		return nil, nil, err
+	}
+	defer reader.Close()
+
+	readOpts := ReadOptions{}
+	if opts != nil {
+		readOpts = *opts
+	}
+	if readOpts.BaseUrl == "" {
+		readOpts.BaseUrl = baseUrl
+	}
+	if readOpts.ContentType == "" {
+		readOpts.ContentType = contentType
+	}
+	return Read(reader, &readOpts)
+}
+
+// openLocation opens playlist source and returns its resolved location and content type
+func openLocation(ctx context.Context, location string, timeout time.Duration) (io.ReadCloser, string, string, error) {
+	if location == StdinLocation {
+		return io.NopCloser(os.Stdin), "", "", nil
+	}
+
+	u, err := url.Parse(location)
+	if err == nil && (u.Scheme == "http" || u.Scheme == "https") {
+		resp, err := util.MakeHTTPRequestTimeout(ctx, "GET", location, nil, nil, nil, timeout)
+		if err != nil {
+			return nil, "", "", err
+		}
+		if resp == nil || resp.Body == nil {
+			return nil, "", "", fmt.Errorf("zero response")
+		}
+		if err = util.CheckHTTPStatus(resp); err != nil {
+			_ = resp.Body.Close()
+			return nil, "", "", err
+		}
+		baseUrl := location
+		if resp.Request != nil && resp.Request.URL != nil {
+			// Redirected location
+			baseUrl = resp.Request.URL.String()
+		}
+		return resp.Body, baseUrl, resp.Header.Get("Content-Type"), nil
+	}
+
+	path := location
+	if err == nil && u.Scheme == "file" {
+		path = u.Path
+	} else if err == nil && u.Scheme != "" && len(u.Scheme) > 1 {
+		return nil, "", "", fmt.Errorf("unsupported playlist location scheme: %s", u.Scheme)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, "", "", err
+	}
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		absPath = path
+	}
+	return f, (&url.URL{Scheme: "file", Path: filepath.ToSlash(absPath)}).String(), "", nil
+}
+
+func ReadUrl(location string, forceReloadChannelData bool, noSampleLoad bool) *Media {
+	media, _, err := Open(location, &ReadOptions{
+		ForceReloadChannelData: forceReloadChannelData,
+		NoSampleLoad:           noSampleLoad,
+	})
+	if err != nil {
+		log.Printf("Failed to read playlist %s: %v\n", location, err)
+		return nil
+	}
+	return media
+}
+
+func readRecords(r io.Reader, opts *ReadOptions) (*Media, error) {
+	media := newMedia(opts)
+	forceEncoding, contentType := "", ""
+	if opts != nil {
+		forceEncoding = opts.Encoding
+		contentType = opts.ContentType
+	}
+
+	r, err := decodeToUTF8(r, forceEncoding, contentType)
+	if err != nil {
+		return media, err
+	}
+
+	sc := bufio.NewScanner(r)
+
+	for sc.Scan() {
+		err = media.AddLine(sc.Text())
+		if err != nil {
+			return media, err
+		}
+	}
+	if sc.Err() != nil {
+		return media, sc.Err()
+	}
+
+	return media, media.finish()
+}
@@ -0,0 +1,103 @@
+package meta
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOpenFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "index.m3u8")
+	err := os.WriteFile(path, []byte("#EXTM3U\n#EXT-X-TARGETDURATION:10\n#EXTINF:10,\nsegments/1.ts\n"), 0644)
+	if err != nil {
+		t.Fatalf("failed to write playlist: %v", err)
+	}
+
+	for _, location := range []string{path, "file://" + filepath.ToSlash(path)} {
+		media, _, err := Open(location, nil)
+		if err != nil {
+			t.Fatalf("Open %s failed: %v", location, err)
+		}
+		if len(media.Segments) != 1 {
+			t.Fatalf("expected 1 segment, got %d", len(media.Segments))
+		}
+		expected := "file://" + filepath.ToSlash(filepath.Join(dir, "segments", "1.ts"))
+		if u := media.ResolveUrl(media.Segments[0].Uri); u != expected {
+			t.Fatalf("invalid resolved url %s, expected %s", u, expected)
+		}
+	}
+
+	_, _, err = Open(filepath.Join(dir, "missing.m3u8"), nil)
+	if err == nil {
+		t.Fatalf("expected error for missing file")
+	}
+}
+
+func TestOpenTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(300 * time.Millisecond)
+		_, _ = w.Write([]byte("#EXTM3U\n"))
+	}))
+	defer server.Close()
+
+	if _, _, err := Open(server.URL, &ReadOptions{Timeout: 50 * time.Millisecond}); err == nil {
+		t.Fatalf("sub-second timeout must be applied")
+	}
+	if _, _, err := Open(server.URL, &ReadOptions{Timeout: 5 * time.Second}); err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+}
+
+func TestParseReport(t *testing.T) {
+	source := "#EXTM3U\n" +
+		"http://host/orphan\n" +
+		"#EXTINF:0,First\n" +
+		"#EXTGRP:A\n" +
+		"#EXTGRP:B\n" +
+		"http://host/first\n" +
+		"#EXTINF:0,Lost\n" +
+		"#EXTINF:0,Second\n" +
+		"http://host/second\n" +
+		"#EXTINF:0,Last\n"
+
+	media, report, err := Parse(strings.NewReader(source), nil)
+	if err != nil {
+		t.Fatalf("lenient Parse failed: %v", err)
+	}
+	expected := []Diagnostic{
+		{Line: 2, Tag: "URI", Severity: SeverityError, Message: "uri without #EXTINF: http://host/orphan"},
+		{Line: 5, Tag: "#EXTGRP", Severity: SeverityWarning, Message: "duplicate group, previous value \"A\" is replaced"},
+		{Line: 7, Tag: "#EXTINF", Severity: SeverityError, Message: "entry has no uri"},
+		{Line: 10, Tag: "#EXTINF", Severity: SeverityError, Message: "entry has no uri"},
+	}
+	if len(report.Diagnostics) != len(expected) {
+		t.Fatalf("unexpected diagnostics: %v", report.Diagnostics)
+	}
+	for i := range expected {
+		if report.Diagnostics[i] != expected[i] {
+			t.Fatalf("diagnostic %d: expected %v, got %v", i, expected[i], report.Diagnostics[i])
+		}
+	}
+
+	filled := 0
+	for _, record := range media.Records {
+		if record.IsFilled() {
+			filled++
+		}
+	}
+	if filled != 2 || media.Records[1].Url != "http://host/first" {
+		t.Fatalf("unexpected records: %+v", media.Records)
+	}
+
+	_, report, err = Parse(strings.NewReader(source), &ReadOptions{Strict: true})
+	var dErr *DiagnosticError
+	if !errors.As(err, &dErr) || dErr.Diagnostic.Line != 2 || len(report.Diagnostics) != 1 {
+		t.Fatalf("strict Parse must stop at line 2, got: %v", err)
+	}
+}
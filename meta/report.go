@@ -0,0 +1,122 @@
+package meta
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+type Severity int
+
+const (
+	SeverityInfo Severity = iota
+	SeverityWarning
+	SeverityError
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityInfo:
+		return "info"
+	case SeverityWarning:
+		return "warning"
+	case SeverityError:
+		return "error"
+	}
+	return fmt.Sprintf("severity(%d)", int(s))
+}
+
+// Diagnostic single playlist problem bound to line number
+type Diagnostic struct {
+	Line     int
+	Tag      string
+	Severity Severity
+	Message  string
+}
+
+func (d Diagnostic) String() string {
+	if d.Tag == "" {
+		return fmt.Sprintf("line %d: %s: %s", d.Line, d.Severity, d.Message)
+	}
+	return fmt.Sprintf("line %d: %s: %s %s", d.Line, d.Severity, d.Tag, d.Message)
+}
+
+// ParseReport diagnostics collected while playlist parsing
+type ParseReport struct {
+	Lines       int
+	Diagnostics []Diagnostic
+}
+
+func (r *ParseReport) add(line int, tag string, severity Severity, message string) Diagnostic {
+	d := Diagnostic{
+		Line:     line,
+		Tag:      tag,
+		Severity: severity,
+		Message:  message,
+	}
+	r.Diagnostics = append(r.Diagnostics, d)
+	return d
+}
+
+// Count diagnostics with severity
+func (r *ParseReport) Count(severity Severity) int {
+	if r == nil {
+		return 0
+	}
+	count := 0
+	for _, d := range r.Diagnostics {
+		if d.Severity == severity {
+			count++
+		}
+	}
+	return count
+}
+
+func (r *ParseReport) HasErrors() bool {
+	return r.Count(SeverityError) > 0
+}
+
+// Filter diagnostics with severity equal or higher than minimal
+func (r *ParseReport) Filter(minSeverity Severity) []Diagnostic {
+	if r == nil {
+		return nil
+	}
+	result := make([]Diagnostic, 0, len(r.Diagnostics))
+	for _, d := range r.Diagnostics {
+		if d.Severity >= minSeverity {
+			result = append(result, d)
+		}
+	}
+	return result
+}
+
+// Write human-readable report, one diagnostic per line
+func (r *ParseReport) Write(w io.Writer, minSeverity Severity) error {
+	for _, d := range r.Filter(minSeverity) {
+		_, err := io.WriteString(w, d.String()+"\n")
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DiagnosticError diagnostic which stopped strict parsing
+type DiagnosticError struct {
+	Diagnostic Diagnostic
+}
+
+func (e *DiagnosticError) Error() string {
+	return e.Diagnostic.String()
+}
+
+// lineTag tag name of playlist line: "#EXTINF" for "#EXTINF:0,Name", "URI" for uri lines
+func lineTag(line string) string {
+	if !strings.HasPrefix(line, "#") {
+		return "URI"
+	}
+	if i := strings.IndexByte(line, ':'); i >= 0 {
+		return line[:i]
+	}
+	return line
+}
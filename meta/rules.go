@@ -0,0 +1,233 @@
+package meta
+
+import (
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"m3u8/cfg"
+	"m3u8/util"
+	"regexp"
+	"strings"
+)
+
+const (
+	RuleActionMove = "move"
+	RuleActionKeep = "keep"
+	RuleActionDrop = "drop"
+)
+
+// Rule matches channels by all set conditions, matched channel is moved to Group,
+// kept in its current group or dropped by Action
+type Rule struct {
+	Group  string
+	Action string
+
+	// Match channel name regex, Exclude names never matched by rule
+	Match   *regexp.Regexp
+	Exclude []*regexp.Regexp
+
+	// Providers host or name, Sources list names, SourceGroups groups of source list, Groups current groups
+	Providers    []string
+	Sources      []string
+	SourceGroups []string
+	Groups       []string
+
+	MinHeight    int
+	MaxHeight    int
+	MinFrameRate int
+	Codecs       []string
+	// Interlaced required field order if set
+	Interlaced *bool
+}
+
+func compileRegexps(patterns []string) ([]*regexp.Regexp, error) {
+	result := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		reg, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, reg)
+	}
+	return result, nil
+}
+
+// ruleKeys known rule config keys
+var ruleKeys = map[string]bool{
+	"action": true, "match": true, "exclude": true, "providers": true, "sources": true, "source_groups": true,
+	"groups": true, "min_height": true, "max_height": true, "min_fps": true, "codecs": true, "interlaced": true,
+}
+
+// hasConditions false if rule matches every channel
+func (r *Rule) hasConditions() bool {
+	return r.Match != nil || len(r.Exclude) != 0 || len(r.Providers) != 0 || len(r.Sources) != 0 ||
+		len(r.SourceGroups) != 0 || len(r.Groups) != 0 || r.MinHeight != 0 || r.MaxHeight != 0 ||
+		r.MinFrameRate != 0 || len(r.Codecs) != 0 || r.Interlaced != nil
+}
+
+// NewRule builds rule of group from config map, unknown keys are logged, rule without conditions fails
+func NewRule(group string, conf map[string]interface{}) (*Rule, error) {
+	for key := range conf {
+		if !ruleKeys[key] {
+			log.Warnf("Unknown key %s in rule of group %s", key, group)
+		}
+	}
+	rule := &Rule{
+		Group:        group,
+		Action:       util.GetValue("action", conf, RuleActionMove),
+		Providers:    util.GetValueArray("providers", conf, []string{}),
+		Sources:      util.GetValueArray("sources", conf, []string{}),
+		SourceGroups: util.GetValueArray("source_groups", conf, []string{}),
+		Groups:       util.GetValueArray("groups", conf, []string{}),
+		MinHeight:    util.GetValue("min_height", conf, 0),
+		MaxHeight:    util.GetValue("max_height", conf, 0),
+		MinFrameRate: util.GetValue("min_fps", conf, 0),
+		Codecs:       util.GetValueArray("codecs", conf, []string{}),
+	}
+	switch rule.Action {
+	case RuleActionMove, RuleActionKeep, RuleActionDrop:
+	default:
+		return nil, fmt.Errorf("unknown rule action %s", rule.Action)
+	}
+
+	var err error
+	if pattern := util.GetValue("match", conf, ""); pattern != "" {
+		rule.Match, err = regexp.Compile(pattern)
+		if err != nil {
+			return nil, err
+		}
+	}
+	rule.Exclude, err = compileRegexps(util.GetValueArray("exclude", conf, []string{}))
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := conf["interlaced"]; ok {
+		interlaced := util.GetValue("interlaced", conf, false)
+		rule.Interlaced = &interlaced
+	}
+	if !rule.hasConditions() {
+		return nil, fmt.Errorf("rule has no conditions and matches every channel")
+	}
+	return rule, nil
+}
+
+// LoadRules reads rules of all groups in config order, broken rules are returned as errors and skipped
+func LoadRules() ([]*Rule, []error) {
+	var rules []*Rule
+	var errs []error
+	for _, item := range cfg.GetGroups() {
+		groupConf, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name := util.GetValue("name", groupConf, "")
+		for i, ruleConf := range util.GetValueArray("rules", groupConf, []map[string]interface{}{}) {
+			rule, err := NewRule(name, ruleConf)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("group %s rule %d: %v", name, i+1, err))
+				continue
+			}
+			rules = append(rules, rule)
+		}
+	}
+	return rules, errs
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// Matches true if channel of groupName meets all rule conditions
+func (r *Rule) Matches(c *Channel, groupName string) bool {
+	if r.Match != nil && !r.Match.MatchString(c.Name) {
+		return false
+	}
+	for _, exclude := range r.Exclude {
+		if exclude.MatchString(c.Name) {
+			return false
+		}
+	}
+	if len(r.Providers) != 0 && !containsFold(r.Providers, c.Provider.Host) && !containsFold(r.Providers, c.Provider.Name) {
+		return false
+	}
+	if len(r.Sources) != 0 && !containsFold(r.Sources, c.Source) {
+		return false
+	}
+	if len(r.SourceGroups) != 0 && !containsFold(r.SourceGroups, c.SourceGroup) {
+		return false
+	}
+	if len(r.Groups) != 0 && !containsFold(r.Groups, groupName) {
+		return false
+	}
+	if r.MinHeight != 0 && c.Height < r.MinHeight {
+		return false
+	}
+	if r.MaxHeight != 0 && c.Height > r.MaxHeight {
+		return false
+	}
+	if r.MinFrameRate != 0 && c.FrameRate < r.MinFrameRate {
+		return false
+	}
+	if len(r.Codecs) != 0 && !containsFold(r.Codecs, c.VideoCodec) {
+		return false
+	}
+	if r.Interlaced != nil && c.IsInterlaced() != *r.Interlaced {
+		return false
+	}
+	return true
+}
+
+func firstRule(rules []*Rule, c *Channel, groupName string) *Rule {
+	for _, rule := range rules {
+		if rule.Matches(c, groupName) {
+			return rule
+		}
+	}
+	return nil
+}
+
+// ApplyRules evaluates rules in order for every channel, first matching rule decides channel group
+func (m *Media) ApplyRules(rules []*Rule) {
+	if len(rules) == 0 {
+		return
+	}
+
+	type move struct {
+		channel *Channel
+		group   string
+	}
+	var moves []move
+
+	for _, group := range m.Groups {
+		if group == nil {
+			continue
+		}
+		kept := group.Channels[:0]
+		for _, channel := range group.Channels {
+			target := group.Name
+			if rule := firstRule(rules, channel, group.Name); rule != nil {
+				switch rule.Action {
+				case RuleActionMove:
+					target = rule.Group
+				case RuleActionDrop:
+					target = ""
+				}
+			}
+			if target == group.Name {
+				kept = append(kept, channel)
+			} else if target != "" {
+				moves = append(moves, move{channel, target})
+			}
+		}
+		group.Channels = kept
+	}
+
+	for _, mv := range moves {
+		group := m.CreateGroup(mv.group)
+		group.Channels = append(group.Channels, mv.channel)
+	}
+}
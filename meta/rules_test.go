@@ -0,0 +1,68 @@
+package meta
+
+import (
+	"m3u8/db"
+	"testing"
+)
+
+func TestApplyRules(t *testing.T) {
+	newRule := func(group string, conf map[string]interface{}) *Rule {
+		rule, err := NewRule(group, conf)
+		if err != nil {
+			t.Fatalf("NewRule failed: %v", err)
+		}
+		return rule
+	}
+	rules := []*Rule{
+		newRule("", map[string]interface{}{"action": "keep", "match": "(?i)trailer"}),
+		newRule("4K", map[string]interface{}{"codecs": []interface{}{"hevc"}, "min_height": 2160}),
+		newRule("кино HD", map[string]interface{}{"match": "^BCU ", "min_height": 1080, "exclude": []interface{}{"(?i)ultra"}}),
+		newRule("кино", map[string]interface{}{"match": "^BCU "}),
+		newRule("", map[string]interface{}{"action": "drop", "providers": []interface{}{"bad.host"}, "source_groups": []interface{}{"XXX"}}),
+	}
+
+	channels := []*Channel{
+		{Name: "BCU Action HD", Height: 1080},
+		{Name: "BCU Marvel", Height: 576},
+		{Name: "BCU Ultra 4K", Height: 2160, VideoCodec: "hevc"},
+		{Name: "BCU Trailer", Height: 1080},
+		{Name: "Adult", SourceGroup: "xxx", Provider: db.Provider{Host: "bad.host"}},
+		{Name: "Adult", SourceGroup: "xxx", Provider: db.Provider{Host: "good.host"}},
+	}
+	media := &Media{Groups: []*Group{{Name: "другие", Channels: append([]*Channel{}, channels...)}}}
+	media.ApplyRules(rules)
+
+	expected := map[string][]*Channel{
+		"другие":  {channels[3], channels[5]},
+		"кино HD": {channels[0]},
+		"кино":    {channels[1]},
+		"4K":      {channels[2]},
+	}
+	if len(media.Groups) != len(expected) {
+		t.Fatalf("unexpected groups count %d", len(media.Groups))
+	}
+	for name, groupChannels := range expected {
+		group, _ := media.FindGroup(name)
+		if group == nil || len(group.Channels) != len(groupChannels) {
+			t.Fatalf("unexpected group %s: %+v", name, group)
+		}
+		for i, channel := range groupChannels {
+			if group.Channels[i] != channel {
+				t.Fatalf("unexpected channel %s in group %s", group.Channels[i].Name, name)
+			}
+		}
+	}
+
+	if _, err := NewRule("кино", map[string]interface{}{"match": "("}); err == nil {
+		t.Fatalf("broken regex must fail")
+	}
+	if _, err := NewRule("кино", map[string]interface{}{"action": "copy", "match": "^BCU "}); err == nil {
+		t.Fatalf("unknown action must fail")
+	}
+	if _, err := NewRule("кино", map[string]interface{}{"action": "drop"}); err == nil {
+		t.Fatalf("rule without conditions must fail")
+	}
+	if _, err := NewRule("кино", map[string]interface{}{"min_heigth": 720}); err == nil {
+		t.Fatalf("rule with misspelled condition only must fail")
+	}
+}
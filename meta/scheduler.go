@@ -0,0 +1,142 @@
+package meta
+
+import (
+	"context"
+	log "github.com/sirupsen/logrus"
+	"m3u8/cfg"
+	"m3u8/semaphore"
+	"sync"
+)
+
+// ProbeTask single probe job, Host is used for per host concurrency limit
+type ProbeTask struct {
+	Host string
+	Run  func(ctx context.Context)
+}
+
+// ProbeScheduler runs probe tasks with limited workers and limited connections per provider host,
+// limits are shared by concurrent Run calls of parallel lists
+type ProbeScheduler struct {
+	Workers int
+	// HostLimit default concurrent tasks per host, providers ban too many connections
+	HostLimit int
+	// HostLimits per host overrides of HostLimit
+	HostLimits map[string]int
+	// Progress is called after every completed task, progress is logged if nil
+	Progress func(done int, total int)
+
+	hosts   map[string]*semaphore.Counter
+	workers *semaphore.Counter
+	mutex   sync.Mutex
+}
+
+// NewProbeScheduler creates scheduler from order config
+func NewProbeScheduler() *ProbeScheduler {
+	return &ProbeScheduler{
+		Workers:    cfg.GetProbeWorkers(),
+		HostLimit:  cfg.GetProbeHostLimit(),
+		HostLimits: cfg.GetProbeHostLimits(),
+	}
+}
+
+func (s *ProbeScheduler) hostCounter(host string) *semaphore.Counter {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.hosts == nil {
+		s.hosts = map[string]*semaphore.Counter{}
+	}
+	counter, ok := s.hosts[host]
+	if !ok {
+		limit, ok := s.HostLimits[host]
+		if !ok {
+			limit = s.HostLimit
+		}
+		if limit <= 0 {
+			limit = 1
+		}
+		counter = semaphore.CreateSemaphore(limit)
+		s.hosts[host] = counter
+	}
+	return counter
+}
+
+func (s *ProbeScheduler) progress(done int, total int) {
+	if s.Progress != nil {
+		s.Progress(done, total)
+		return
+	}
+	step := total / 10
+	if step == 0 {
+		step = 1
+	}
+	if done%step == 0 || done == total {
+		log.Printf("Probed %d/%d channels", done, total)
+	}
+}
+
+// workerCounter worker slots shared by all Run calls of scheduler
+func (s *ProbeScheduler) workerCounter() *semaphore.Counter {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.workers == nil {
+		workers := s.Workers
+		if workers <= 0 {
+			workers = 1
+		}
+		s.workers = semaphore.CreateSemaphore(workers)
+	}
+	return s.workers
+}
+
+// Run executes tasks and waits for completion, returns context error if canceled.
+// Tasks of each host are started in order, host slot is taken before worker slot,
+// so tasks waiting for busy host never hold workers needed by other hosts.
+func (s *ProbeScheduler) Run(ctx context.Context, tasks []ProbeTask) error {
+	var hosts []string
+	queues := map[string][]*ProbeTask{}
+	for i := range tasks {
+		host := tasks[i].Host
+		if _, ok := queues[host]; !ok {
+			hosts = append(hosts, host)
+		}
+		queues[host] = append(queues[host], &tasks[i])
+	}
+
+	workers := s.workerCounter()
+	wg := sync.WaitGroup{}
+	doneMutex := sync.Mutex{}
+	done := 0
+
+	for _, host := range hosts {
+		wg.Add(1)
+		go func(counter *semaphore.Counter, queue []*ProbeTask) {
+			defer wg.Done()
+			for _, task := range queue {
+				if ctx.Err() != nil || counter.Acquire(ctx) != nil {
+					return
+				}
+				if ctx.Err() != nil || workers.Acquire(ctx) != nil {
+					counter.Complete()
+					return
+				}
+				wg.Add(1)
+				go func(task *ProbeTask) {
+					defer wg.Done()
+					task.Run(ctx)
+					workers.Complete()
+					counter.Complete()
+
+					doneMutex.Lock()
+					done++
+					s.progress(done, len(tasks))
+					doneMutex.Unlock()
+				}(task)
+			}
+		}(s.hostCounter(host), queues[host])
+	}
+	wg.Wait()
+
+	return ctx.Err()
+}
@@ -0,0 +1,179 @@
+package meta
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestProbeSchedulerHostLimits(t *testing.T) {
+	scheduler := &ProbeScheduler{
+		Workers:    6,
+		HostLimit:  2,
+		HostLimits: map[string]int{"strict.host": 1},
+	}
+
+	mutex := sync.Mutex{}
+	running := map[string]int{}
+	maxRunning := map[string]int{}
+	var lastDone int64
+
+	scheduler.Progress = func(done int, total int) {
+		atomic.StoreInt64(&lastDone, int64(done))
+	}
+
+	var tasks []ProbeTask
+	for i := 0; i < 30; i++ {
+		host := "wide.host"
+		if i%3 == 0 {
+			host = "strict.host"
+		}
+		tasks = append(tasks, ProbeTask{Host: host, Run: func(ctx context.Context) {
+			mutex.Lock()
+			running[host]++
+			if running[host] > maxRunning[host] {
+				maxRunning[host] = running[host]
+			}
+			mutex.Unlock()
+
+			time.Sleep(5 * time.Millisecond)
+
+			mutex.Lock()
+			running[host]--
+			mutex.Unlock()
+		}})
+	}
+
+	err := scheduler.Run(context.Background(), tasks)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if lastDone != 30 {
+		t.Fatalf("expected 30 completed tasks, got %d", lastDone)
+	}
+	if maxRunning["strict.host"] != 1 || maxRunning["wide.host"] > 2 {
+		t.Fatalf("host limits exceeded: %v", maxRunning)
+	}
+}
+
+func TestProbeSchedulerCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var started int64
+
+	var tasks []ProbeTask
+	for i := 0; i < 20; i++ {
+		tasks = append(tasks, ProbeTask{Host: "host", Run: func(ctx context.Context) {
+			if atomic.AddInt64(&started, 1) == 2 {
+				cancel()
+			}
+			<-ctx.Done()
+		}})
+	}
+
+	scheduler := &ProbeScheduler{Workers: 2, HostLimit: 2, Progress: func(int, int) {}}
+	err := scheduler.Run(ctx, tasks)
+	if err != context.Canceled {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if started != 2 {
+		t.Fatalf("tasks must not start after cancel, started %d", started)
+	}
+}
+
+func TestProbeSchedulerBusyHost(t *testing.T) {
+	release := make(chan struct{})
+	fastDone := make(chan struct{})
+	var tasks []ProbeTask
+	for i := 0; i < 10; i++ {
+		tasks = append(tasks, ProbeTask{Host: "busy.host", Run: func(ctx context.Context) { <-release }})
+	}
+	tasks = append(tasks, ProbeTask{Host: "other.host", Run: func(ctx context.Context) { close(fastDone) }})
+
+	scheduler := &ProbeScheduler{Workers: 2, HostLimit: 1, Progress: func(int, int) {}}
+	result := make(chan error)
+	go func() { result <- scheduler.Run(context.Background(), tasks) }()
+
+	select {
+	case <-fastDone:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("task of other host must not wait behind busy host")
+	}
+	close(release)
+	if err := <-result; err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+}
+
+func TestProbeSchedulerSharedWorkers(t *testing.T) {
+	scheduler := &ProbeScheduler{Workers: 2, HostLimit: 10, Progress: func(int, int) {}}
+	var running, maxRunning int64
+	var tasks []ProbeTask
+	for i := 0; i < 10; i++ {
+		tasks = append(tasks, ProbeTask{Host: "host", Run: func(ctx context.Context) {
+			current := atomic.AddInt64(&running, 1)
+			for {
+				prev := atomic.LoadInt64(&maxRunning)
+				if current <= prev || atomic.CompareAndSwapInt64(&maxRunning, prev, current) {
+					break
+				}
+			}
+			time.Sleep(5 * time.Millisecond)
+			atomic.AddInt64(&running, -1)
+		}})
+	}
+
+	// Parallel lists share scheduler workers
+	wg := sync.WaitGroup{}
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = scheduler.Run(context.Background(), append([]ProbeTask(nil), tasks...))
+		}()
+	}
+	wg.Wait()
+	if maxRunning > 2 {
+		t.Fatalf("parallel runs exceeded workers: %d", maxRunning)
+	}
+}
+
+func TestLoadChannels(t *testing.T) {
+	source := "#EXTM3U\n" +
+		"#EXTINF:0 group-title=\"Кино\",Kino HD\nhttp://a.host/iptv/key/101/index.m3u8\n" +
+		"#EXTINF:0 group-title=\"Кино\",Kino SD\nhttp://a.host/iptv/key/102/index.m3u8\n" +
+		"#EXTINF:0 group-title=\"Спорт\",Sport\nhttp://b.host/iptv/key/201/index.m3u8\n"
+
+	fake := NewFakeProber().
+		Add("http://a.host/iptv/key/101/index.m3u8", FakeMetaData(1920, 1080, 25)).
+		Add("http://a.host/iptv/key/102/index.m3u8", FakeMetaData(720, 576, 25)).
+		Add("http://b.host/iptv/key/201/index.m3u8", FakeMetaData(1280, 720, 50))
+
+	media, _, err := Read(strings.NewReader(source), &ReadOptions{Prober: fake})
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if len(fake.Calls()) != 0 {
+		t.Fatalf("channels must not be probed while reading")
+	}
+
+	err = media.LoadChannels(context.Background(), &ProbeScheduler{Workers: 3, HostLimit: 1, Progress: func(int, int) {}})
+	if err != nil {
+		t.Fatalf("LoadChannels failed: %v", err)
+	}
+	if len(fake.Calls()) != 3 {
+		t.Fatalf("expected 3 probes, got %v", fake.Calls())
+	}
+	heights := map[string]int{}
+	for _, group := range media.Groups {
+		for _, channel := range group.Channels {
+			heights[channel.Name] = channel.Height
+		}
+	}
+	if heights["Kino HD"] != 1080 || heights["Kino SD"] != 576 || heights["Sport"] != 720 {
+		t.Fatalf("unexpected channel heights: %v", heights)
+	}
+}
@@ -0,0 +1,311 @@
+package meta
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const programDateTimeFormat = "2006-01-02T15:04:05.000Z07:00"
+
+// parseProgramDateTime parses RFC 3339 time and common offset form without colon: 2024-07-23T21:45:55.000+0000
+func parseProgramDateTime(value string) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err == nil {
+		return t, nil
+	}
+	if t, tzErr := time.Parse("2006-01-02T15:04:05.999999999Z0700", value); tzErr == nil {
+		return t, nil
+	}
+	return t, err
+}
+
+// ByteRange #EXT-X-BYTERANGE:<n>[@<o>]
+type ByteRange struct {
+	Length    int64
+	Offset    int64
+	HasOffset bool
+}
+
+func ParseByteRange(data string) (*ByteRange, error) {
+	args := strings.SplitN(strings.TrimSpace(data), "@", 2)
+	length, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid byte range length %s: %v", data, err)
+	}
+	byteRange := ByteRange{Length: length}
+	if len(args) > 1 {
+		byteRange.Offset, err = strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid byte range offset %s: %v", data, err)
+		}
+		byteRange.HasOffset = true
+	}
+	return &byteRange, nil
+}
+
+func (b *ByteRange) String() string {
+	if b.HasOffset {
+		return strconv.FormatInt(b.Length, 10) + "@" + strconv.FormatInt(b.Offset, 10)
+	}
+	return strconv.FormatInt(b.Length, 10)
+}
+
+// Key #EXT-X-KEY:METHOD=AES-128,URI="https://...",IV=0x...
+type Key struct {
+	Method            string
+	Uri               string
+	IV                string
+	KeyFormat         string
+	KeyFormatVersions string
+
+	Attributes *Attributes
+}
+
+func newKey(attributes *Attributes) *Key {
+	return &Key{
+		Method:            attributes.Value("METHOD"),
+		Uri:               attributes.Value("URI"),
+		IV:                attributes.Value("IV"),
+		KeyFormat:         attributes.Value("KEYFORMAT"),
+		KeyFormatVersions: attributes.Value("KEYFORMATVERSIONS"),
+		Attributes:        attributes,
+	}
+}
+
+func (k *Key) String() string {
+	attributes := k.Attributes.Clone()
+	setNotEmpty(attributes, "METHOD", k.Method)
+	setNotEmpty(attributes, "URI", k.Uri)
+	setNotEmpty(attributes, "IV", k.IV)
+	setNotEmpty(attributes, "KEYFORMAT", k.KeyFormat)
+	setNotEmpty(attributes, "KEYFORMATVERSIONS", k.KeyFormatVersions)
+	return "#EXT-X-KEY:" + FormatAttributeList(attributes)
+}
+
+// MediaMap #EXT-X-MAP:URI="init.mp4",BYTERANGE="720@0"
+type MediaMap struct {
+	Uri       string
+	ByteRange *ByteRange
+
+	Attributes *Attributes
+}
+
+func newMediaMap(attributes *Attributes) (*MediaMap, error) {
+	mediaMap := MediaMap{
+		Uri:        attributes.Value("URI"),
+		Attributes: attributes,
+	}
+	if attributes.Has("BYTERANGE") {
+		var err error
+		mediaMap.ByteRange, err = ParseByteRange(attributes.Value("BYTERANGE"))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &mediaMap, nil
+}
+
+func (m *MediaMap) String() string {
+	attributes := m.Attributes.Clone()
+	setNotEmpty(attributes, "URI", m.Uri)
+	if m.ByteRange != nil {
+		attributes.Set("BYTERANGE", m.ByteRange.String())
+	}
+	return "#EXT-X-MAP:" + FormatAttributeList(attributes)
+}
+
+// Segment single media segment of media playlist
+type Segment struct {
+	Duration float64
+	Title    string
+	Uri      string
+
+	ByteRange       *ByteRange
+	Key             *Key
+	Map             *MediaMap
+	Discontinuity   bool
+	ProgramDateTime time.Time
+}
+
+// IsMediaPlaylist playlist contains segments description
+func (m *Media) IsMediaPlaylist() bool {
+	return m.mediaPlaylist
+}
+
+func (m *Media) segment() *Segment {
+	if m.pendingSegment == nil {
+		m.pendingSegment = &Segment{
+			Key: m.currentKey,
+			Map: m.currentMap,
+		}
+	}
+	return m.pendingSegment
+}
+
+// addPlaylistLine handles media playlist tags, returns true if line is fully processed
+func (m *Media) addPlaylistLine(line string) (bool, error) {
+	var err error
+
+	switch {
+	case strings.HasPrefix(line, "#EXT-X-VERSION:"):
+		m.Version, err = strconv.Atoi(strings.TrimSpace(line[len("#EXT-X-VERSION:"):]))
+	case strings.HasPrefix(line, "#EXT-X-INDEPENDENT-SEGMENTS"):
+		m.IndependentSegments = true
+	case strings.HasPrefix(line, "#EXT-X-TARGETDURATION:"):
+		m.mediaPlaylist = true
+		m.TargetDuration, err = strconv.Atoi(strings.TrimSpace(line[len("#EXT-X-TARGETDURATION:"):]))
+	case strings.HasPrefix(line, "#EXT-X-MEDIA-SEQUENCE:"):
+		m.mediaPlaylist = true
+		m.MediaSequence, err = strconv.ParseInt(strings.TrimSpace(line[len("#EXT-X-MEDIA-SEQUENCE:"):]), 10, 64)
+	case strings.HasPrefix(line, "#EXT-X-DISCONTINUITY-SEQUENCE:"):
+		m.mediaPlaylist = true
+		m.DiscontinuitySequence, err = strconv.ParseInt(strings.TrimSpace(line[len("#EXT-X-DISCONTINUITY-SEQUENCE:"):]), 10, 64)
+	case strings.HasPrefix(line, "#EXT-X-PLAYLIST-TYPE:"):
+		m.mediaPlaylist = true
+		m.PlaylistType = strings.TrimSpace(line[len("#EXT-X-PLAYLIST-TYPE:"):])
+	case strings.HasPrefix(line, "#EXT-X-ENDLIST"):
+		m.mediaPlaylist = true
+		m.EndList = true
+	case strings.HasPrefix(line, "#EXT-X-KEY:"):
+		m.mediaPlaylist = true
+		m.currentKey = newKey(ParseAttributeList(line[len("#EXT-X-KEY:"):]))
+		if m.currentKey.Method == "NONE" {
+			m.currentKey = nil
+		}
+		m.segment().Key = m.currentKey
+	case strings.HasPrefix(line, "#EXT-X-MAP:"):
+		m.mediaPlaylist = true
+		m.currentMap, err = newMediaMap(ParseAttributeList(line[len("#EXT-X-MAP:"):]))
+		m.segment().Map = m.currentMap
+	case strings.HasPrefix(line, "#EXT-X-BYTERANGE:"):
+		m.mediaPlaylist = true
+		m.segment().ByteRange, err = ParseByteRange(line[len("#EXT-X-BYTERANGE:"):])
+	case strings.HasPrefix(line, "#EXT-X-DISCONTINUITY"):
+		m.mediaPlaylist = true
+		m.segment().Discontinuity = true
+	case strings.HasPrefix(line, "#EXT-X-PROGRAM-DATE-TIME:"):
+		m.mediaPlaylist = true
+		m.segment().ProgramDateTime, err = parseProgramDateTime(strings.TrimSpace(line[len("#EXT-X-PROGRAM-DATE-TIME:"):]))
+	case !m.mediaPlaylist:
+		// Channel list, segments are not collected
+		return false, nil
+	case strings.HasPrefix(line, "#EXTINF:"):
+		// #EXTINF:10.000000,title
+		extInf := ParseExtInf(line[len("#EXTINF:"):])
+		segment := m.segment()
+		segment.Title = extInf.Title
+		segment.Duration, err = strconv.ParseFloat(extInf.Duration, 64)
+		return false, err
+	case line != "" && !strings.HasPrefix(line, "#"):
+		segment := m.segment()
+		segment.Uri = line
+		m.Segments = append(m.Segments, segment)
+		m.pendingSegment = nil
+		return false, nil
+	default:
+		return false, nil
+	}
+
+	return true, err
+}
+
+// TotalDuration sum of all segment durations
+func (m *Media) TotalDuration() time.Duration {
+	var total float64
+	for _, segment := range m.Segments {
+		total += segment.Duration
+	}
+	return time.Duration(math.Round(total * float64(time.Second)))
+}
+
+// WritePlaylist serializes master or media playlist
+func (m *Media) WritePlaylist(w io.Writer) error {
+	lines := []string{"#EXTM3U"}
+	if m.Version > 0 {
+		lines = append(lines, "#EXT-X-VERSION:"+strconv.Itoa(m.Version))
+	}
+	if m.IndependentSegments {
+		lines = append(lines, "#EXT-X-INDEPENDENT-SEGMENTS")
+	}
+
+	if m.IsMaster() {
+		lines = append(lines, m.masterLines()...)
+	} else {
+		lines = append(lines, m.mediaLines()...)
+	}
+
+	for _, line := range lines {
+		_, err := io.WriteString(w, line+"\n")
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *Media) masterLines() []string {
+	lines := make([]string, 0, len(m.Renditions)+len(m.Variants)*2+len(m.IFrameVariants))
+
+	for _, rendition := range m.Renditions {
+		lines = append(lines, "#EXT-X-MEDIA:"+FormatAttributeList(rendition.attributeList()))
+	}
+	for _, variant := range m.Variants {
+		lines = append(lines, "#EXT-X-STREAM-INF:"+FormatAttributeList(variant.attributeList()), variant.Uri)
+	}
+	for _, variant := range m.IFrameVariants {
+		lines = append(lines, "#EXT-X-I-FRAME-STREAM-INF:"+FormatAttributeList(variant.attributeList()))
+	}
+	return lines
+}
+
+func (m *Media) mediaLines() []string {
+	lines := make([]string, 0, 5+len(m.Segments)*3)
+
+	lines = append(lines, "#EXT-X-TARGETDURATION:"+strconv.Itoa(m.TargetDuration))
+	if m.MediaSequence != 0 {
+		lines = append(lines, "#EXT-X-MEDIA-SEQUENCE:"+strconv.FormatInt(m.MediaSequence, 10))
+	}
+	if m.DiscontinuitySequence != 0 {
+		lines = append(lines, "#EXT-X-DISCONTINUITY-SEQUENCE:"+strconv.FormatInt(m.DiscontinuitySequence, 10))
+	}
+	if m.PlaylistType != "" {
+		lines = append(lines, "#EXT-X-PLAYLIST-TYPE:"+m.PlaylistType)
+	}
+
+	var key *Key
+	var mediaMap *MediaMap
+	for _, segment := range m.Segments {
+		if segment.Key != key {
+			if segment.Key == nil {
+				lines = append(lines, "#EXT-X-KEY:METHOD=NONE")
+			} else {
+				lines = append(lines, segment.Key.String())
+			}
+			key = segment.Key
+		}
+		if segment.Map != mediaMap && segment.Map != nil {
+			lines = append(lines, segment.Map.String())
+			mediaMap = segment.Map
+		}
+		if segment.Discontinuity {
+			lines = append(lines, "#EXT-X-DISCONTINUITY")
+		}
+		if !segment.ProgramDateTime.IsZero() {
+			lines = append(lines, "#EXT-X-PROGRAM-DATE-TIME:"+segment.ProgramDateTime.Format(programDateTimeFormat))
+		}
+		lines = append(lines, "#EXTINF:"+strconv.FormatFloat(segment.Duration, 'f', -1, 64)+","+segment.Title)
+		if segment.ByteRange != nil {
+			lines = append(lines, "#EXT-X-BYTERANGE:"+segment.ByteRange.String())
+		}
+		lines = append(lines, segment.Uri)
+	}
+
+	if m.EndList {
+		lines = append(lines, "#EXT-X-ENDLIST")
+	}
+	return lines
+}
@@ -0,0 +1,188 @@
+package meta
+
+import (
+	"fmt"
+	"m3u8/cfg"
+	"m3u8/util"
+	"strings"
+)
+
+// TierGroupPlaceholder replaced by split group name in tier group names
+const TierGroupPlaceholder = "{group}"
+
+// QualityTier matches channels by resolution and frame rate, matched channels are moved to Name group
+type QualityTier struct {
+	Name string
+
+	// MinWidth or MinHeight reached is enough, wide films are 1920x800
+	MinWidth  int
+	MinHeight int
+	// MaxHeight matches only channels of known resolution
+	MaxHeight    int
+	MinFrameRate int
+}
+
+// DefaultQualityTiers single HD sibling split used if no tiers are configured
+var DefaultQualityTiers = []*QualityTier{{Name: TierGroupPlaceholder + " HD", MinWidth: 1920, MinHeight: 1080}}
+
+// NewQualityTier builds tier from config map
+func NewQualityTier(conf map[string]interface{}) (*QualityTier, error) {
+	tier := &QualityTier{
+		Name:         util.GetValue("name", conf, ""),
+		MinWidth:     util.GetValue("min_width", conf, 0),
+		MinHeight:    util.GetValue("min_height", conf, 0),
+		MaxHeight:    util.GetValue("max_height", conf, 0),
+		MinFrameRate: util.GetValue("min_fps", conf, 0),
+	}
+	if tier.Name == "" {
+		return nil, fmt.Errorf("tier name is empty")
+	}
+	return tier, nil
+}
+
+// GroupName tier group name of split group
+func (t *QualityTier) GroupName(groupName string) string {
+	return strings.ReplaceAll(t.Name, TierGroupPlaceholder, groupName)
+}
+
+// Matches true if channel meets all tier conditions
+func (t *QualityTier) Matches(c *Channel) bool {
+	if t.MinWidth != 0 || t.MinHeight != 0 {
+		wide := t.MinWidth != 0 && c.Width >= t.MinWidth
+		high := t.MinHeight != 0 && c.Height >= t.MinHeight
+		if !wide && !high {
+			return false
+		}
+	}
+	if t.MaxHeight != 0 && (c.Height == 0 || c.Height > t.MaxHeight) {
+		return false
+	}
+	if t.MinFrameRate != 0 && c.FrameRate < t.MinFrameRate {
+		return false
+	}
+	return true
+}
+
+func loadTiers(confs []map[string]interface{}) ([]*QualityTier, error) {
+	tiers := make([]*QualityTier, 0, len(confs))
+	for i, conf := range confs {
+		tier, err := NewQualityTier(conf)
+		if err != nil {
+			return nil, fmt.Errorf("tier %d: %v", i+1, err)
+		}
+		tiers = append(tiers, tier)
+	}
+	return tiers, nil
+}
+
+// LoadQualityTiers reads tiers by split group name, groups of group_hd_split use quality_tiers
+// or DefaultQualityTiers, group own tiers override them; groups with broken tiers are skipped
+func LoadQualityTiers() (map[string][]*QualityTier, []error) {
+	var errs []error
+	defaults := DefaultQualityTiers
+	if confs := cfg.GetQualityTiers(); len(confs) != 0 {
+		tiers, err := loadTiers(confs)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("quality_tiers %v", err))
+		} else {
+			defaults = tiers
+		}
+	}
+
+	result := map[string][]*QualityTier{}
+	for _, name := range cfg.GetHDSplit() {
+		result[name] = defaults
+	}
+	for _, item := range cfg.GetGroups() {
+		groupConf, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		confs := util.GetValueArray("tiers", groupConf, []map[string]interface{}{})
+		if len(confs) == 0 {
+			continue
+		}
+		name := util.GetValue("name", groupConf, "")
+		tiers, err := loadTiers(confs)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("group %s %v", name, err))
+			delete(result, name)
+			continue
+		}
+		result[name] = tiers
+	}
+	return result, errs
+}
+
+// SplitTiers moves channels of group to first matching tier group, fullSearch returns channels
+// of existing templated tier groups to group first so channels of lower quality go back;
+// shared tier groups without placeholder like "4K" are never merged back
+func (m *Media) SplitTiers(groupName string, tiers []*QualityTier, fullSearch bool) {
+	group, _ := m.FindGroup(groupName)
+	if group == nil {
+		return
+	}
+
+	if fullSearch {
+		for _, tier := range tiers {
+			if !strings.Contains(tier.Name, TierGroupPlaceholder) {
+				continue
+			}
+			tierGroup, _ := m.FindGroup(tier.GroupName(groupName))
+			if tierGroup != nil && tierGroup != group {
+				group.mergeChannels(tierGroup)
+			}
+		}
+	}
+
+	kept := group.Channels[:0]
+	moved := make([][]*Channel, len(tiers))
+	for _, channel := range group.Channels {
+		target := -1
+		for i, tier := range tiers {
+			if tier.Matches(channel) {
+				target = i
+				break
+			}
+		}
+		if target < 0 || tiers[target].GroupName(groupName) == groupName {
+			kept = append(kept, channel)
+			continue
+		}
+		moved[target] = append(moved[target], channel)
+	}
+	group.Channels = kept
+	// Tier groups are created only for moved channels
+	for i, channels := range moved {
+		if len(channels) != 0 {
+			tierGroup := m.CreateGroup(tiers[i].GroupName(groupName))
+			tierGroup.Channels = append(tierGroup.Channels, channels...)
+		}
+	}
+}
+
+// SplitQualityTiers splits groups by their tiers, tier groups of other split groups are never split again
+func (m *Media) SplitQualityTiers(groupTiers map[string][]*QualityTier) {
+	targets := map[string]bool{}
+	for groupName, tiers := range groupTiers {
+		for _, tier := range tiers {
+			if target := tier.GroupName(groupName); target != groupName {
+				targets[target] = true
+			}
+		}
+	}
+
+	// Groups are split in media order to keep created groups order stable
+	var splitList []string
+	for _, group := range m.Groups {
+		if group == nil || targets[group.Name] {
+			continue
+		}
+		if _, ok := groupTiers[group.Name]; ok {
+			splitList = append(splitList, group.Name)
+		}
+	}
+	for _, groupName := range splitList {
+		m.SplitTiers(groupName, groupTiers[groupName], true)
+	}
+}
@@ -0,0 +1,65 @@
+package meta
+
+import "testing"
+
+func TestSplitQualityTiers(t *testing.T) {
+	newTier := func(conf map[string]interface{}) *QualityTier {
+		tier, err := NewQualityTier(conf)
+		if err != nil {
+			t.Fatalf("NewQualityTier failed: %v", err)
+		}
+		return tier
+	}
+	sportTiers := []*QualityTier{
+		newTier(map[string]interface{}{"name": "{group} 50fps", "min_fps": 50}),
+		newTier(map[string]interface{}{"name": "{group} SD", "max_height": 719}),
+	}
+	filmTiers := []*QualityTier{
+		newTier(map[string]interface{}{"name": "{group} 4K", "min_height": 2160}),
+		newTier(map[string]interface{}{"name": "{group} HD", "min_width": 1920, "min_height": 1080}),
+	}
+
+	channels := []*Channel{
+		{Name: "Матч HD", Width: 1920, Height: 1080, FrameRate: 50},
+		{Name: "Футбол", Width: 720, Height: 576, FrameRate: 25},
+		{Name: "Спорт", Width: 1280, Height: 720, FrameRate: 25},
+		{Name: "Unknown"},
+		{Name: "BCU Ultra 4K", Width: 3840, Height: 2160},
+		{Name: "Wide HD", Width: 1920, Height: 800},
+		{Name: "Кино", Width: 1280, Height: 720},
+		{Name: "Старое HD", Width: 1280, Height: 720},
+	}
+	media := &Media{Groups: []*Group{
+		{Name: "спорт", Channels: []*Channel{channels[0], channels[1], channels[2], channels[3]}},
+		{Name: "кино", Channels: []*Channel{channels[4], channels[5], channels[6]}},
+		{Name: "кино HD", Channels: []*Channel{channels[7]}},
+	}}
+	media.SplitQualityTiers(map[string][]*QualityTier{"спорт": sportTiers, "кино": filmTiers, "кино HD": filmTiers})
+
+	expected := map[string][]*Channel{
+		"спорт":       {channels[2], channels[3]},
+		"спорт 50fps": {channels[0]},
+		"спорт SD":    {channels[1]},
+		"кино":        {channels[6], channels[7]},
+		"кино HD":     {channels[5]},
+		"кино 4K":     {channels[4]},
+	}
+	if len(media.Groups) != len(expected) {
+		t.Fatalf("unexpected groups count %d", len(media.Groups))
+	}
+	for name, groupChannels := range expected {
+		group, _ := media.FindGroup(name)
+		if group == nil || len(group.Channels) != len(groupChannels) {
+			t.Fatalf("unexpected group %s: %+v", name, group)
+		}
+		for i, channel := range groupChannels {
+			if group.Channels[i] != channel {
+				t.Fatalf("unexpected channel %s in group %s", group.Channels[i].Name, name)
+			}
+		}
+	}
+
+	if _, err := NewQualityTier(map[string]interface{}{"min_fps": 50}); err == nil {
+		t.Fatalf("tier without name must fail")
+	}
+}
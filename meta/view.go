@@ -0,0 +1,138 @@
+package meta
+
+import (
+	"fmt"
+	"m3u8/cfg"
+	"m3u8/util"
+	"regexp"
+)
+
+// HealthUnknown output health filter status of not probed channels
+const HealthUnknown = "unknown"
+
+// outputView filters and transforms channels of one output, media is never changed
+type outputView struct {
+	output *cfg.Output
+
+	includeGroups   []*regexp.Regexp
+	excludeGroups   []*regexp.Regexp
+	includeChannels []*regexp.Regexp
+	excludeChannels []*regexp.Regexp
+	// rename output names by normalized source name
+	rename map[string]string
+}
+
+func newOutputView(output *cfg.Output) (*outputView, error) {
+	view := &outputView{output: output, rename: map[string]string{}}
+	var err error
+	for _, item := range []struct {
+		target   *[]*regexp.Regexp
+		patterns []string
+	}{
+		{&view.includeGroups, output.IncludeGroups},
+		{&view.excludeGroups, output.ExcludeGroups},
+		{&view.includeChannels, output.IncludeChannels},
+		{&view.excludeChannels, output.ExcludeChannels},
+	} {
+		*item.target, err = compileRegexps(item.patterns)
+		if err != nil {
+			return nil, fmt.Errorf("output %s filter: %v", output.FileName, err)
+		}
+	}
+	for name, newName := range output.Rename {
+		view.rename[NormalizeName(name)] = newName
+	}
+	return view, nil
+}
+
+func matchAny(regexps []*regexp.Regexp, value string) bool {
+	for _, reg := range regexps {
+		if reg.MatchString(value) {
+			return true
+		}
+	}
+	return false
+}
+
+// group output name of group, false if group is filtered out
+func (v *outputView) group(name string) (string, bool) {
+	if util.Contains(v.output.SkipGroups, name) {
+		return "", false
+	}
+	if len(v.includeGroups) != 0 && !matchAny(v.includeGroups, name) {
+		return "", false
+	}
+	if matchAny(v.excludeGroups, name) {
+		return "", false
+	}
+	if mapped, ok := v.output.GroupMap[name]; ok && mapped != "" {
+		return mapped, true
+	}
+	return name, true
+}
+
+// accepts true if channel passes output channel filters
+func (v *outputView) accepts(c *Channel) bool {
+	output := v.output
+	if len(v.includeChannels) != 0 && !matchAny(v.includeChannels, c.Name) {
+		return false
+	}
+	if matchAny(v.excludeChannels, c.Name) {
+		return false
+	}
+	if output.MinHeight != 0 && c.Height < output.MinHeight {
+		return false
+	}
+	if len(output.Providers) != 0 && !containsFold(output.Providers, c.Provider.Host) && !containsFold(output.Providers, c.Provider.Name) {
+		return false
+	}
+	if containsFold(output.ExcludeProviders, c.Provider.Host) || containsFold(output.ExcludeProviders, c.Provider.Name) {
+		return false
+	}
+	if len(output.Health) != 0 {
+		status := c.Health.Status
+		if status == "" {
+			status = HealthUnknown
+		}
+		if !containsFold(output.Health, status) {
+			return false
+		}
+	}
+	if output.Catchup && c.HistoryDays <= 0 {
+		return false
+	}
+	return true
+}
+
+// channel output copy of channel with output name, false if channel is filtered out
+func (v *outputView) channel(c *Channel) (*Channel, bool) {
+	if !v.accepts(c) {
+		return nil, false
+	}
+	newName, ok := v.rename[NormalizeName(c.Name)]
+	if !ok || newName == c.Name {
+		return c, true
+	}
+	renamed := *c
+	renamed.Name = newName
+	return &renamed, true
+}
+
+// primary output copy of cluster primary: channel itself if accepted and alive, otherwise first accepted
+// live backup or first accepted one; false if output accepts no channel of cluster
+func (v *outputView) primary(c *Channel) (*Channel, bool) {
+	output := v.output
+	live := func(channel *Channel) bool {
+		return v.accepts(channel) && !(isDeadOutput(output) && channel.IsDead(output.DeadFailures))
+	}
+	if !live(c) {
+		if promoted := promoteBackup(c, live); promoted != nil {
+			c = promoted
+		} else if !v.accepts(c) {
+			if promoted = promoteBackup(c, v.accepts); promoted != nil {
+				c = promoted
+			}
+		}
+	}
+	return v.channel(c)
+}
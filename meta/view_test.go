@@ -0,0 +1,91 @@
+package meta
+
+import (
+	"bytes"
+	"m3u8/cfg"
+	"m3u8/db"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestOutputView(t *testing.T) {
+	channels := []*Channel{
+		{Name: "Первый канал HD", Url: "http://a/1", Height: 1080, HistoryDays: 3, Health: ChannelHealth{Status: HealthOk}},
+		{Name: "Кино", Url: "http://a/2", Height: 576, HistoryDays: 3},
+		{Name: "Кино HD", Url: "http://b/3", Height: 1080, Provider: db.Provider{Host: "bad.host"}},
+		{Name: "Мульт", Url: "http://a/4", Height: 720, Health: ChannelHealth{Status: HealthTimeout, Failures: 1}},
+	}
+	media := &Media{Groups: []*Group{
+		{Name: "HD", Channels: []*Channel{channels[0]}},
+		{Name: "кино", Channels: []*Channel{channels[1], channels[2]}},
+		{Name: "детские", Channels: []*Channel{channels[3]}},
+	}}
+	write := func(output *cfg.Output) string {
+		var buf bytes.Buffer
+		if err := media.write(&buf, output, ""); err != nil {
+			t.Fatalf("write failed: %v", err)
+		}
+		return buf.String()
+	}
+
+	result := write(&cfg.Output{
+		ExcludeGroups:    []string{"^дет"},
+		MinHeight:        720,
+		ExcludeProviders: []string{"bad.host"},
+		Rename:           map[string]string{"Первый канал HD": "Channel One HD"},
+		GroupMap:         map[string]string{"HD": "Main"},
+	})
+	if !strings.Contains(result, ",Channel One HD\n#EXTGRP:Main\nhttp://a/1") || strings.Count(result, "http://") != 1 {
+		t.Fatalf("unexpected filtered output:\n%s", result)
+	}
+
+	result = write(&cfg.Output{IncludeChannels: []string{"(?i)^(кино|мульт)"}, Health: []string{HealthUnknown, HealthTimeout}})
+	if !strings.Contains(result, "http://a/2") || !strings.Contains(result, "http://b/3") || !strings.Contains(result, "http://a/4") ||
+		strings.Contains(result, "http://a/1") {
+		t.Fatalf("unexpected health output:\n%s", result)
+	}
+
+	result = write(&cfg.Output{Catchup: true})
+	if strings.Count(result, "http://") != 2 {
+		t.Fatalf("unexpected catchup output:\n%s", result)
+	}
+
+	if channels[0].Name != "Первый канал HD" || media.Groups[0].Name != "HD" || len(media.Groups[1].Channels) != 2 {
+		t.Fatalf("output view must not change media")
+	}
+	if err := media.write(&bytes.Buffer{}, &cfg.Output{IncludeGroups: []string{"("}}, ""); err == nil {
+		t.Fatalf("broken filter regex must fail")
+	}
+	fileName := filepath.Join(t.TempDir(), "out.m3u8")
+	if err := os.WriteFile(fileName, []byte("#EXTM3U\n"), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	media.WriteFile(&cfg.Output{FileName: fileName, ExcludeChannels: []string{"("}}, "")
+	if data, _ := os.ReadFile(fileName); string(data) != "#EXTM3U\n" {
+		t.Fatalf("broken filter must keep previous file: %q", data)
+	}
+}
+
+func TestOutputViewPromotesBackup(t *testing.T) {
+	backup := &Channel{Name: "Кино", Url: "http://b/1", Height: 720, Provider: db.Provider{Host: "b"}}
+	primary := &Channel{Name: "Кино", Url: "http://a/1", Height: 1080, Provider: db.Provider{Host: "a"}, Backups: []*Channel{backup}}
+	media := &Media{Groups: []*Group{{Name: "кино", Channels: []*Channel{primary}}}, dedupMode: cfg.DedupModeAttribute, backupAttribute: "backup-url"}
+
+	var buf bytes.Buffer
+	if err := media.write(&buf, &cfg.Output{ExcludeProviders: []string{"a"}}, ""); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	if !strings.Contains(buf.String(), "\nhttp://b/1\n") || strings.Contains(buf.String(), "http://a/1") {
+		t.Fatalf("accepted backup must replace rejected primary:\n%s", buf.String())
+	}
+
+	buf.Reset()
+	if err := media.write(&buf, &cfg.Output{MinHeight: 1080}, ""); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	if strings.Count(buf.String(), "http://") != 1 || !strings.Contains(buf.String(), "\nhttp://a/1\n") {
+		t.Fatalf("rejected backup must be skipped:\n%s", buf.String())
+	}
+}
@@ -0,0 +1,3 @@
+alter table channel drop column video_codec, drop column video_profile, drop column video_level,
+    drop column field_order, drop column pix_fmt, drop column bit_rate,
+    drop column audio_codecs, drop column audio_channels, drop column audio_languages, drop column has_subtitles;
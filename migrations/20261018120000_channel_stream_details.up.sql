@@ -0,0 +1,10 @@
+alter table channel add column video_codec text default '' not null;
+alter table channel add column video_profile text default '' not null;
+alter table channel add column video_level integer default 0 not null;
+alter table channel add column field_order text default '' not null;
+alter table channel add column pix_fmt text default '' not null;
+alter table channel add column bit_rate integer default 0 not null;
+alter table channel add column audio_codecs text[] default '{}' not null;
+alter table channel add column audio_channels integer[] default '{}' not null;
+alter table channel add column audio_languages text[] default '{}' not null;
+alter table channel add column has_subtitles boolean default false not null;
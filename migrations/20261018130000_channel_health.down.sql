@@ -0,0 +1,2 @@
+drop table channel_probe;
+drop table channel_health;
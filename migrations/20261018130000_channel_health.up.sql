@@ -0,0 +1,19 @@
+create table channel_health
+(
+    channel_id           bigint primary key references channel (id) on delete cascade,
+    status               text                                   not null,
+    consecutive_failures integer                  default 0     not null,
+    last_ok_at           timestamp with time zone,
+    checked_at           timestamp with time zone default now() not null
+);
+
+create table channel_probe
+(
+    id         bigserial primary key,
+    channel_id bigint                                 not null references channel (id) on delete cascade,
+    status     text                                   not null,
+    message    text,
+    checked_at timestamp with time zone default now() not null
+);
+
+create index channel_probe_channel_idx on channel_probe (channel_id, checked_at);
@@ -0,0 +1,5 @@
+create table channel_alias
+(
+    alias text primary key,
+    name  text not null
+);
@@ -0,0 +1,10 @@
+create table channel_number
+(
+    scope       text                                   not null,
+    channel_key text                                   not null,
+    canonical   text                                   not null,
+    number      integer                                not null,
+    updated_at  timestamp with time zone default now() not null,
+    primary key (scope, channel_key),
+    unique (scope, number)
+);
@@ -1,53 +1,145 @@
 package semaphore
 
 import (
+	"container/list"
+	"context"
 	"sync"
-	"time"
 )
 
-type Counter struct {
-	mx              sync.Mutex
-	processingCount int
-	accessLimit     int
+type waiter struct {
+	weight int64
+	ready  chan struct{}
 }
 
-func (p *Counter) StartNext() bool {
-	if !p.CanStartNext() {
-		return false
+// Weighted semaphore, waiters are served in FIFO order so heavy acquires are not starved
+type Weighted struct {
+	size    int64
+	cur     int64
+	mx      sync.Mutex
+	waiters list.List
+}
+
+func NewWeighted(size int64) *Weighted {
+	return &Weighted{size: size}
+}
+
+// Acquire blocks till weight is available or ctx is done, nothing is acquired on error
+func (s *Weighted) Acquire(ctx context.Context, weight int64) error {
+	s.mx.Lock()
+	if s.size-s.cur >= weight && s.waiters.Len() == 0 {
+		s.cur += weight
+		s.mx.Unlock()
+		return nil
+	}
+
+	if weight > s.size {
+		// Never satisfiable, wait for cancellation only
+		s.mx.Unlock()
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	ready := make(chan struct{})
+	elem := s.waiters.PushBack(waiter{weight: weight, ready: ready})
+	s.mx.Unlock()
+
+	select {
+	case <-ctx.Done():
+		s.mx.Lock()
+		select {
+		case <-ready:
+			// Acquired right after cancellation, give it back
+			s.cur -= weight
+			s.notifyWaiters()
+		default:
+			isFront := s.waiters.Front() == elem
+			s.waiters.Remove(elem)
+			if isFront && s.size > s.cur {
+				s.notifyWaiters()
+			}
+		}
+		s.mx.Unlock()
+		return ctx.Err()
+	case <-ready:
+		return nil
 	}
-	p.mx.Lock()
-	defer p.mx.Unlock()
-	p.processingCount++
-	return true
 }
 
-func (p *Counter) CanStartNext() bool {
-	p.mx.Lock()
-	defer p.mx.Unlock()
-	return p.processingCount < p.accessLimit
+// TryAcquire acquires weight without blocking, returns false if it is not available
+func (s *Weighted) TryAcquire(weight int64) bool {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	if s.size-s.cur >= weight && s.waiters.Len() == 0 {
+		s.cur += weight
+		return true
+	}
+	return false
 }
 
-func (p *Counter) WaitAvailable() {
-	for true {
-		if p.StartNext() {
+// Release returns weight, panics if more than held is released
+func (s *Weighted) Release(weight int64) {
+	s.mx.Lock()
+	s.cur -= weight
+	if s.cur < 0 {
+		s.mx.Unlock()
+		panic("semaphore: released more than held")
+	}
+	s.notifyWaiters()
+	s.mx.Unlock()
+}
+
+func (s *Weighted) notifyWaiters() {
+	for {
+		next := s.waiters.Front()
+		if next == nil {
+			break
+		}
+		w := next.Value.(waiter)
+		if s.size-s.cur < w.weight {
+			// FIFO: smaller waiters behind must not overtake
 			break
 		}
-		time.Sleep(time.Millisecond)
+		s.cur += w.weight
+		s.waiters.Remove(next)
+		close(w.ready)
 	}
 }
 
+// Counter semaphore of unit weight
+type Counter struct {
+	w *Weighted
+}
+
+func (p *Counter) StartNext() bool {
+	return p.w.TryAcquire(1)
+}
+
+func (p *Counter) CanStartNext() bool {
+	p.w.mx.Lock()
+	defer p.w.mx.Unlock()
+	return p.w.cur < p.w.size && p.w.waiters.Len() == 0
+}
+
+// WaitAvailable blocks till counter is started
+func (p *Counter) WaitAvailable() {
+	_ = p.w.Acquire(context.Background(), 1)
+}
+
+// Acquire blocks till counter is started or ctx is done
+func (p *Counter) Acquire(ctx context.Context) error {
+	return p.w.Acquire(ctx, 1)
+}
+
 func (p *Counter) Complete() {
-	p.mx.Lock()
-	defer p.mx.Unlock()
-	if p.processingCount > 0 {
-		p.processingCount--
+	p.w.mx.Lock()
+	defer p.w.mx.Unlock()
+	if p.w.cur > 0 {
+		p.w.cur--
+		p.w.notifyWaiters()
 	}
 }
 
 func CreateSemaphore(accessLimit int) *Counter {
-	return &Counter{
-		mx:              sync.Mutex{},
-		processingCount: 0,
-		accessLimit:     accessLimit,
-	}
+	return &Counter{w: NewWeighted(int64(accessLimit))}
 }
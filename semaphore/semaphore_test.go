@@ -0,0 +1,161 @@
+package semaphore
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWeightedFifo(t *testing.T) {
+	s := NewWeighted(3)
+	ctx := context.Background()
+
+	if err := s.Acquire(ctx, 2); err != nil {
+		t.Fatalf("Acquire failed: %v", err)
+	}
+
+	order := make(chan int, 2)
+	go func() {
+		_ = s.Acquire(ctx, 3)
+		order <- 3
+		s.Release(3)
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	// Free unit is available, but heavy waiter is first in queue
+	if s.TryAcquire(1) {
+		t.Fatalf("TryAcquire must not overtake waiters")
+	}
+	go func() {
+		_ = s.Acquire(ctx, 1)
+		order <- 1
+		s.Release(1)
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	s.Release(2)
+	if first, second := <-order, <-order; first != 3 || second != 1 {
+		t.Fatalf("waiters are not served in FIFO order: %d, %d", first, second)
+	}
+	if !s.TryAcquire(3) {
+		t.Fatalf("semaphore must be free")
+	}
+}
+
+func TestWeightedCancel(t *testing.T) {
+	s := NewWeighted(1)
+	if !s.TryAcquire(1) {
+		t.Fatalf("TryAcquire failed")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := s.Acquire(ctx, 1); err != context.DeadlineExceeded {
+		t.Fatalf("expected deadline error, got %v", err)
+	}
+	if err := s.Acquire(ctx, 2); err != context.DeadlineExceeded {
+		t.Fatalf("expected deadline error for oversized weight, got %v", err)
+	}
+
+	s.Release(1)
+	if !s.TryAcquire(1) {
+		t.Fatalf("canceled waiter must not hold weight")
+	}
+
+	counter := CreateSemaphore(1)
+	counter.WaitAvailable()
+	if counter.CanStartNext() || counter.StartNext() {
+		t.Fatalf("counter limit exceeded")
+	}
+	counter.Complete()
+	counter.Complete()
+	if !counter.StartNext() || counter.StartNext() {
+		t.Fatalf("extra Complete must not raise limit")
+	}
+}
+
+func TestSingleFlight(t *testing.T) {
+	var group SingleFlight[int]
+	var calls int64
+	release := make(chan struct{})
+
+	wg := sync.WaitGroup{}
+	results := make([]int, 10)
+	sharedCount := int64(0)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			val, err, shared := group.Do(context.Background(), "key", func() (int, error) {
+				atomic.AddInt64(&calls, 1)
+				<-release
+				return 42, nil
+			})
+			if err != nil {
+				t.Errorf("Do failed: %v", err)
+			}
+			if shared {
+				atomic.AddInt64(&sharedCount, 1)
+			}
+			results[i] = val
+		}(i)
+	}
+
+	for !group.Pending("key") {
+		time.Sleep(time.Millisecond)
+	}
+	// Let remaining callers join running call
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if calls != 1 || sharedCount != 9 {
+		t.Fatalf("expected single call with 9 shared results, got %d calls, %d shared", calls, sharedCount)
+	}
+	for _, val := range results {
+		if val != 42 {
+			t.Fatalf("unexpected result %d", val)
+		}
+	}
+	if group.Pending("key") {
+		t.Fatalf("finished key must not be pending")
+	}
+}
+
+func TestSingleFlightLeaderCancel(t *testing.T) {
+	var group SingleFlight[int]
+	leaderCtx, cancel := context.WithCancel(context.Background())
+	leaderDone := make(chan error)
+	go func() {
+		_, err, _ := group.Do(leaderCtx, "key", func() (int, error) {
+			<-leaderCtx.Done()
+			return 0, leaderCtx.Err()
+		})
+		leaderDone <- err
+	}()
+	for !group.Pending("key") {
+		time.Sleep(time.Millisecond)
+	}
+
+	followerDone := make(chan int)
+	go func() {
+		val, err, _ := group.Do(context.Background(), "key", func() (int, error) {
+			return 42, nil
+		})
+		if err != nil {
+			t.Errorf("follower with live context failed: %v", err)
+		}
+		followerDone <- val
+	}()
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+
+	if err := <-leaderDone; err != context.Canceled {
+		t.Fatalf("expected leader cancel, got %v", err)
+	}
+	if val := <-followerDone; val != 42 {
+		t.Fatalf("follower must run own call after leader cancel, got %d", val)
+	}
+}
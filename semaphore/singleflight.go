@@ -0,0 +1,69 @@
+package semaphore
+
+import (
+	"context"
+	"sync"
+)
+
+type flight[T any] struct {
+	done chan struct{}
+	val  T
+	err  error
+	// canceled ctx of running caller was done when fn returned, result is not shared
+	canceled bool
+}
+
+// SingleFlight runs one call per key at a time, concurrent callers of same key share its result
+type SingleFlight[T any] struct {
+	mx      sync.Mutex
+	flights map[string]*flight[T]
+}
+
+// Do runs fn or waits for running call of key, shared is true if result comes from another caller.
+// Waiting caller returns ctx error on cancellation, running fn is not interrupted.
+// If ctx of running caller is done, waiting callers with live ctx run their own fn.
+func (g *SingleFlight[T]) Do(ctx context.Context, key string, fn func() (T, error)) (val T, err error, shared bool) {
+	for {
+		g.mx.Lock()
+		if g.flights == nil {
+			g.flights = map[string]*flight[T]{}
+		}
+		f, ok := g.flights[key]
+		if !ok {
+			break
+		}
+		g.mx.Unlock()
+		select {
+		case <-f.done:
+			if f.canceled && ctx.Err() == nil {
+				continue
+			}
+			return f.val, f.err, true
+		case <-ctx.Done():
+			return val, ctx.Err(), true
+		}
+	}
+
+	f := &flight[T]{done: make(chan struct{})}
+	g.flights[key] = f
+	g.mx.Unlock()
+
+	defer func() {
+		g.mx.Lock()
+		delete(g.flights, key)
+		g.mx.Unlock()
+		close(f.done)
+	}()
+
+	f.val, f.err = fn()
+	f.canceled = ctx.Err() != nil
+	return f.val, f.err, false
+}
+
+// Pending returns true if call of key is running
+func (g *SingleFlight[T]) Pending(key string) bool {
+	g.mx.Lock()
+	defer g.mx.Unlock()
+	_, ok := g.flights[key]
+	return ok
+}
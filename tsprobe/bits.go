@@ -0,0 +1,85 @@
+package tsprobe
+
+import "errors"
+
+var errBitsEnd = errors.New("unexpected end of bitstream")
+
+// bitReader reads big-endian bits and exp-Golomb codes from NAL unit payload
+type bitReader struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+func newBitReader(data []byte) *bitReader {
+	return &bitReader{data: data}
+}
+
+func (r *bitReader) u(n int) uint32 {
+	var v uint32
+	for i := 0; i < n; i++ {
+		if r.pos >= len(r.data)*8 {
+			r.err = errBitsEnd
+			return 0
+		}
+		bit := (r.data[r.pos/8] >> (7 - uint(r.pos%8))) & 1
+		v = v<<1 | uint32(bit)
+		r.pos++
+	}
+	return v
+}
+
+func (r *bitReader) flag() bool {
+	return r.u(1) == 1
+}
+
+func (r *bitReader) skip(n int) {
+	r.pos += n
+	if r.pos > len(r.data)*8 {
+		r.err = errBitsEnd
+	}
+}
+
+// ue unsigned exp-Golomb code
+func (r *bitReader) ue() uint32 {
+	zeros := 0
+	for !r.flag() {
+		if r.err != nil || zeros > 31 {
+			r.err = errBitsEnd
+			return 0
+		}
+		zeros++
+	}
+	if zeros == 0 {
+		return 0
+	}
+	return (1<<uint(zeros) - 1) + r.u(zeros)
+}
+
+// se signed exp-Golomb code
+func (r *bitReader) se() int32 {
+	v := r.ue()
+	if v%2 == 0 {
+		return -int32(v / 2)
+	}
+	return int32(v/2) + 1
+}
+
+// unescapeRbsp removes emulation prevention bytes: 00 00 03 -> 00 00
+func unescapeRbsp(data []byte) []byte {
+	out := make([]byte, 0, len(data))
+	zeros := 0
+	for _, b := range data {
+		if zeros >= 2 && b == 3 {
+			zeros = 0
+			continue
+		}
+		out = append(out, b)
+		if b == 0 {
+			zeros++
+		} else {
+			zeros = 0
+		}
+	}
+	return out
+}
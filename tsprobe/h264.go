@@ -0,0 +1,242 @@
+package tsprobe
+
+import "strconv"
+
+// videoInfo stream parameters from sequence header, zero frame rate if not signalled
+type videoInfo struct {
+	Codec   string
+	Profile string
+	Level   int
+	PixFmt  string
+	// FieldOrder "progressive" or "tt" for interlaced, field parity is not signalled in sequence header
+	FieldOrder string
+
+	Width  int
+	Height int
+
+	FrameRateNum int
+	FrameRateDen int
+}
+
+func (v *videoInfo) setFrameRate(num int, den int) {
+	if num <= 0 || den <= 0 {
+		return
+	}
+	d := gcd(num, den)
+	v.FrameRateNum = num / d
+	v.FrameRateDen = den / d
+}
+
+func gcd(a int, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
+// pixFmt ffprobe pixel format name of planar YUV
+func pixFmt(chromaFormatIdc uint32, bitDepth uint32) string {
+	var format string
+	switch chromaFormatIdc {
+	case 0:
+		format = "gray"
+	case 2:
+		format = "yuv422p"
+	case 3:
+		format = "yuv444p"
+	default:
+		format = "yuv420p"
+	}
+	if bitDepth > 8 {
+		format += strconv.Itoa(int(bitDepth)) + "le"
+	}
+	return format
+}
+
+func fieldOrder(progressive bool) string {
+	if progressive {
+		return "progressive"
+	}
+	return "tt"
+}
+
+func h264Profile(profileIdc uint32, constraints uint32) string {
+	switch profileIdc {
+	case 66:
+		if constraints&0x40 != 0 {
+			return "Constrained Baseline"
+		}
+		return "Baseline"
+	case 77:
+		return "Main"
+	case 88:
+		return "Extended"
+	case 100:
+		return "High"
+	case 110:
+		return "High 10"
+	case 122:
+		return "High 4:2:2"
+	case 244:
+		return "High 4:4:4 Predictive"
+	}
+	return ""
+}
+
+// cropUnits chroma subsampling multipliers for cropping offsets: SubWidthC, SubHeightC
+func cropUnits(chromaFormatIdc uint32) (int, int) {
+	switch chromaFormatIdc {
+	case 1:
+		return 2, 2
+	case 2:
+		return 2, 1
+	default:
+		return 1, 1
+	}
+}
+
+func skipH264ScalingList(r *bitReader, size int) {
+	last, next := int32(8), int32(8)
+	for i := 0; i < size; i++ {
+		if next != 0 {
+			next = (last + r.se() + 256) % 256
+		}
+		if next != 0 {
+			last = next
+		}
+	}
+}
+
+// parseH264Sps parses H.264 sequence parameter set without NAL header
+func parseH264Sps(data []byte) (*videoInfo, error) {
+	r := newBitReader(unescapeRbsp(data))
+
+	profileIdc := r.u(8)
+	constraints := r.u(8)
+	levelIdc := r.u(8)
+	r.ue() // seq_parameter_set_id
+
+	chromaFormatIdc := uint32(1)
+	separateColourPlane := false
+	bitDepth := uint32(8)
+	switch profileIdc {
+	case 100, 110, 122, 244, 44, 83, 86, 118, 128, 138, 139, 134, 135:
+		chromaFormatIdc = r.ue()
+		if chromaFormatIdc == 3 {
+			separateColourPlane = r.flag()
+		}
+		bitDepth += r.ue() // bit_depth_luma_minus8
+		r.ue()             // bit_depth_chroma_minus8
+		r.skip(1)          // qpprime_y_zero_transform_bypass_flag
+		if r.flag() {
+			lists := 8
+			if chromaFormatIdc == 3 {
+				lists = 12
+			}
+			for i := 0; i < lists; i++ {
+				if !r.flag() {
+					continue
+				}
+				if i < 6 {
+					skipH264ScalingList(r, 16)
+				} else {
+					skipH264ScalingList(r, 64)
+				}
+			}
+		}
+	}
+
+	r.ue() // log2_max_frame_num_minus4
+	switch r.ue() {
+	case 0:
+		r.ue() // log2_max_pic_order_cnt_lsb_minus4
+	case 1:
+		r.skip(1) // delta_pic_order_always_zero_flag
+		r.se()    // offset_for_non_ref_pic
+		r.se()    // offset_for_top_to_bottom_field
+		cycle := r.ue()
+		for i := uint32(0); i < cycle && r.err == nil; i++ {
+			r.se()
+		}
+	}
+	r.ue()    // max_num_ref_frames
+	r.skip(1) // gaps_in_frame_num_value_allowed_flag
+
+	widthInMbs := int(r.ue()) + 1
+	heightInMapUnits := int(r.ue()) + 1
+	frameMbsOnly := r.flag()
+	if !frameMbsOnly {
+		r.skip(1) // mb_adaptive_frame_field_flag
+	}
+	r.skip(1) // direct_8x8_inference_flag
+
+	fieldFactor := 1
+	if !frameMbsOnly {
+		fieldFactor = 2
+	}
+	info := &videoInfo{
+		Codec:      "h264",
+		Profile:    h264Profile(profileIdc, constraints),
+		Level:      int(levelIdc),
+		PixFmt:     pixFmt(chromaFormatIdc, bitDepth),
+		FieldOrder: fieldOrder(frameMbsOnly),
+		Width:      widthInMbs * 16,
+		Height:     fieldFactor * heightInMapUnits * 16,
+	}
+
+	if r.flag() {
+		cropX, cropY := 1, fieldFactor
+		if !separateColourPlane && chromaFormatIdc != 0 {
+			subWidth, subHeight := cropUnits(chromaFormatIdc)
+			cropX = subWidth
+			cropY = subHeight * fieldFactor
+		}
+		left, right := int(r.ue()), int(r.ue())
+		top, bottom := int(r.ue()), int(r.ue())
+		info.Width -= cropX * (left + right)
+		info.Height -= cropY * (top + bottom)
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	if r.flag() {
+		parseH264Vui(r, info)
+	}
+	return info, nil
+}
+
+func skipVuiHeader(r *bitReader) {
+	if r.flag() { // aspect_ratio_info_present_flag
+		if r.u(8) == 255 {
+			r.skip(32) // sar_width, sar_height
+		}
+	}
+	if r.flag() { // overscan_info_present_flag
+		r.skip(1)
+	}
+	if r.flag() { // video_signal_type_present_flag
+		r.skip(4)
+		if r.flag() {
+			r.skip(24)
+		}
+	}
+	if r.flag() { // chroma_loc_info_present_flag
+		r.ue()
+		r.ue()
+	}
+}
+
+func parseH264Vui(r *bitReader, info *videoInfo) {
+	skipVuiHeader(r)
+	if !r.flag() { // timing_info_present_flag
+		return
+	}
+	numUnitsInTick := r.u(32)
+	timeScale := r.u(32)
+	if r.err != nil {
+		return
+	}
+	// Two field ticks per frame
+	info.setFrameRate(int(timeScale), 2*int(numUnitsInTick))
+}
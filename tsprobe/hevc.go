@@ -0,0 +1,219 @@
+package tsprobe
+
+// hevcProfileTierLevel general profile and level are returned, sub layers are skipped
+type hevcProfileTierLevel struct {
+	ProfileIdc  uint32
+	LevelIdc    uint32
+	Progressive bool
+	Interlaced  bool
+}
+
+func parseHevcProfileTierLevel(r *bitReader, maxSubLayersMinus1 int) hevcProfileTierLevel {
+	var ptl hevcProfileTierLevel
+	r.skip(3) // general_profile_space, general_tier_flag
+	ptl.ProfileIdc = r.u(5)
+	r.skip(32) // general_profile_compatibility_flags
+	ptl.Progressive = r.flag()
+	ptl.Interlaced = r.flag()
+	r.skip(2 + 43 + 1) // non packed, frame only and reserved flags
+	ptl.LevelIdc = r.u(8)
+
+	profilePresent := make([]bool, maxSubLayersMinus1)
+	levelPresent := make([]bool, maxSubLayersMinus1)
+	for i := 0; i < maxSubLayersMinus1; i++ {
+		profilePresent[i] = r.flag()
+		levelPresent[i] = r.flag()
+	}
+	if maxSubLayersMinus1 > 0 {
+		r.skip(2 * (8 - maxSubLayersMinus1))
+	}
+	for i := 0; i < maxSubLayersMinus1; i++ {
+		if profilePresent[i] {
+			r.skip(88)
+		}
+		if levelPresent[i] {
+			r.skip(8)
+		}
+	}
+	return ptl
+}
+
+func hevcProfile(profileIdc uint32) string {
+	switch profileIdc {
+	case 1:
+		return "Main"
+	case 2:
+		return "Main 10"
+	case 3:
+		return "Main Still Picture"
+	case 4:
+		return "Rext"
+	}
+	return ""
+}
+
+func skipHevcScalingListData(r *bitReader) {
+	for sizeId := 0; sizeId < 4; sizeId++ {
+		step := 1
+		if sizeId == 3 {
+			step = 3
+		}
+		for matrixId := 0; matrixId < 6; matrixId += step {
+			if !r.flag() { // scaling_list_pred_mode_flag
+				r.ue()
+				continue
+			}
+			coefNum := 1 << uint(4+(sizeId<<1))
+			if coefNum > 64 {
+				coefNum = 64
+			}
+			if sizeId > 1 {
+				r.se() // scaling_list_dc_coef_minus8
+			}
+			for i := 0; i < coefNum && r.err == nil; i++ {
+				r.se()
+			}
+		}
+	}
+}
+
+// maxHevcShortTermRefPicSets spec limit of num_short_term_ref_pic_sets
+const maxHevcShortTermRefPicSets = 64
+
+// skipHevcShortTermRefPicSets returns false on malformed sets
+func skipHevcShortTermRefPicSets(r *bitReader, count int) bool {
+	if count < 0 || count > maxHevcShortTermRefPicSets {
+		return false
+	}
+	numDeltaPocs := make([]int, count)
+	for idx := 0; idx < count; idx++ {
+		interPrediction := idx != 0 && r.flag()
+		if interPrediction {
+			// Sets in SPS always predict from previous one, delta_idx_minus1 is signalled in slice headers only
+			r.skip(1) // delta_rps_sign
+			r.ue()    // abs_delta_rps_minus1
+			refDeltas := numDeltaPocs[idx-1]
+			for j := 0; j <= refDeltas; j++ {
+				used := r.flag()
+				if used || r.flag() {
+					numDeltaPocs[idx]++
+				}
+			}
+		} else {
+			negative := int(r.ue())
+			positive := int(r.ue())
+			if negative > 16 || positive > 16 {
+				return false
+			}
+			for i := 0; i < negative+positive; i++ {
+				r.ue()    // delta_poc_minus1
+				r.skip(1) // used_by_curr_pic_flag
+			}
+			numDeltaPocs[idx] = negative + positive
+		}
+		if r.err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// parseHevcSps parses H.265 sequence parameter set without NAL header
+func parseHevcSps(data []byte) (*videoInfo, error) {
+	r := newBitReader(unescapeRbsp(data))
+
+	r.skip(4) // sps_video_parameter_set_id
+	maxSubLayersMinus1 := int(r.u(3))
+	r.skip(1) // sps_temporal_id_nesting_flag
+	ptl := parseHevcProfileTierLevel(r, maxSubLayersMinus1)
+
+	r.ue() // sps_seq_parameter_set_id
+	chromaFormatIdc := r.ue()
+	separateColourPlane := false
+	if chromaFormatIdc == 3 {
+		separateColourPlane = r.flag()
+	}
+
+	info := &videoInfo{
+		Codec:      "hevc",
+		Profile:    hevcProfile(ptl.ProfileIdc),
+		Level:      int(ptl.LevelIdc),
+		FieldOrder: fieldOrder(ptl.Progressive || !ptl.Interlaced),
+		Width:      int(r.ue()),
+		Height:     int(r.ue()),
+	}
+	if r.flag() { // conformance_window_flag
+		subWidth, subHeight := 1, 1
+		if !separateColourPlane {
+			subWidth, subHeight = cropUnits(chromaFormatIdc)
+		}
+		left, right := int(r.ue()), int(r.ue())
+		top, bottom := int(r.ue()), int(r.ue())
+		info.Width -= subWidth * (left + right)
+		info.Height -= subHeight * (top + bottom)
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	info.PixFmt = pixFmt(chromaFormatIdc, 8+r.ue()) // bit_depth_luma_minus8
+	r.ue()                                          // bit_depth_chroma_minus8
+
+	// Everything below is needed to reach VUI timing info only
+	log2MaxPocLsb := int(r.ue()) + 4
+	first := maxSubLayersMinus1
+	if r.flag() { // sps_sub_layer_ordering_info_present_flag
+		first = 0
+	}
+	for i := first; i <= maxSubLayersMinus1; i++ {
+		r.ue()
+		r.ue()
+		r.ue()
+	}
+	for i := 0; i < 6; i++ {
+		r.ue() // coding and transform block sizes, hierarchy depths
+	}
+	if r.flag() { // scaling_list_enabled_flag
+		if r.flag() {
+			skipHevcScalingListData(r)
+		}
+	}
+	r.skip(2)     // amp_enabled_flag, sample_adaptive_offset_enabled_flag
+	if r.flag() { // pcm_enabled_flag
+		r.skip(8)
+		r.ue()
+		r.ue()
+		r.skip(1)
+	}
+	if !skipHevcShortTermRefPicSets(r, int(r.ue())) {
+		return info, nil
+	}
+	if r.flag() { // long_term_ref_pics_present_flag
+		count := int(r.ue())
+		for i := 0; i < count && r.err == nil; i++ {
+			r.skip(log2MaxPocLsb + 1)
+		}
+	}
+	r.skip(2) // sps_temporal_mvp_enabled_flag, strong_intra_smoothing_enabled_flag
+
+	if r.err != nil || !r.flag() { // vui_parameters_present_flag
+		return info, nil
+	}
+	skipVuiHeader(r)
+	r.skip(3)     // neutral_chroma, field_seq, frame_field_info flags
+	if r.flag() { // default_display_window_flag
+		r.ue()
+		r.ue()
+		r.ue()
+		r.ue()
+	}
+	if !r.flag() { // vui_timing_info_present_flag
+		return info, nil
+	}
+	numUnitsInTick := r.u(32)
+	timeScale := r.u(32)
+	if r.err == nil {
+		info.setFrameRate(int(timeScale), int(numUnitsInTick))
+	}
+	return info, nil
+}
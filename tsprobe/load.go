@@ -0,0 +1,74 @@
+package tsprobe
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"m3u8/ffprobe"
+	"m3u8/util"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// DefaultMaxBytes enough for several keyframes of HD stream
+const DefaultMaxBytes = 512 * 1024
+
+// Prober downloads first MaxBytes of segment or live stream and probes it
+type Prober struct {
+	MaxBytes       int64
+	TimeoutSeconds uint32
+}
+
+func (p *Prober) Probe(ctx context.Context, remoteId string, streamUrl string) (*ffprobe.MetaData, error) {
+	return LoadMetaData(ctx, streamUrl, p.MaxBytes, p.TimeoutSeconds)
+}
+
+// LoadMetaData downloads first maxBytes of segment or live stream and probes it
+func LoadMetaData(ctx context.Context, streamUrl string, maxBytes int64, timeoutSeconds uint32) (*ffprobe.MetaData, error) {
+	if maxBytes <= 0 {
+		maxBytes = DefaultMaxBytes
+	}
+	if timeoutSeconds == 0 {
+		timeoutSeconds = 20
+	}
+
+	reader, err := open(ctx, streamUrl, timeoutSeconds)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	metaData, err := Probe(io.LimitReader(reader, maxBytes))
+	if err != nil {
+		return nil, err
+	}
+	metaData.Format.FileName = streamUrl
+	return metaData, nil
+}
+
+func open(ctx context.Context, streamUrl string, timeoutSeconds uint32) (io.ReadCloser, error) {
+	if !strings.Contains(streamUrl, "://") {
+		return os.Open(streamUrl)
+	}
+
+	u, err := url.Parse(streamUrl)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "file":
+		return os.Open(u.Path)
+	case "http", "https":
+		resp, err := util.MakeHTTPRequestContext(ctx, "GET", streamUrl, nil, nil, nil, timeoutSeconds)
+		if err != nil {
+			return nil, err
+		}
+		if err = util.CheckHTTPStatus(resp); err != nil {
+			resp.Body.Close()
+			return nil, err
+		}
+		return resp.Body, nil
+	}
+	return nil, fmt.Errorf("unsupported url scheme %s", u.Scheme)
+}
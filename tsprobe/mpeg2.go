@@ -0,0 +1,48 @@
+package tsprobe
+
+// mpeg2FrameRates frame_rate_code table, index 0 is forbidden
+var mpeg2FrameRates = [][2]int{
+	{0, 0}, {24000, 1001}, {24, 1}, {25, 1}, {30000, 1001}, {30, 1}, {50, 1}, {60000, 1001}, {60, 1},
+}
+
+// parseMpeg2Sequence parses MPEG-2 sequence header after 00 00 01 B3 start code
+func parseMpeg2Sequence(data []byte) (*videoInfo, error) {
+	r := newBitReader(data)
+	info := &videoInfo{
+		Codec:  "mpeg2video",
+		PixFmt: "yuv420p",
+		Width:  int(r.u(12)),
+		Height: int(r.u(12)),
+	}
+	r.skip(4) // aspect_ratio_information
+	code := int(r.u(4))
+	if r.err != nil {
+		return nil, r.err
+	}
+	if code < len(mpeg2FrameRates) {
+		info.setFrameRate(mpeg2FrameRates[code][0], mpeg2FrameRates[code][1])
+	}
+	return info, nil
+}
+
+var mpeg2Profiles = map[uint32]string{1: "High", 2: "Spatially Scalable", 3: "SNR Scalable", 4: "Main", 5: "Simple"}
+
+// parseMpeg2Extension applies sequence extension after 00 00 01 B5 start code, other extensions are ignored
+func parseMpeg2Extension(data []byte, info *videoInfo) {
+	r := newBitReader(data)
+	if r.u(4) != 1 {
+		return
+	}
+	r.skip(1) // escape bit
+	profile := r.u(3)
+	level := r.u(4)
+	progressive := r.flag()
+	chromaFormat := r.u(2)
+	if r.err != nil {
+		return
+	}
+	info.Profile = mpeg2Profiles[profile]
+	info.Level = int(level)
+	info.FieldOrder = fieldOrder(progressive)
+	info.PixFmt = pixFmt(chromaFormat, 8)
+}
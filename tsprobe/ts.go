@@ -0,0 +1,407 @@
+package tsprobe
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"m3u8/ffprobe"
+	"sort"
+)
+
+const (
+	packetSize = 188
+	syncByte   = 0x47
+
+	patPid = 0
+
+	// ptsSamples enough PES timestamps to estimate frame rate without VUI timing
+	ptsSamples = 16
+	// maxPesBuffer drops PES payload without sequence header, keyframes with SPS are far smaller
+	maxPesBuffer = 1 << 20
+)
+
+var (
+	ErrNoProgram  = errors.New("no program map table found")
+	ErrNoSequence = errors.New("no video sequence header found")
+	// ErrPlaylist data is a playlist, segment url is expected
+	ErrPlaylist = errors.New("playlist instead of transport stream")
+)
+
+type elementaryStream struct {
+	Pid       int
+	CodecType string
+	CodecName string
+	Language  string
+}
+
+type demuxer struct {
+	pmtPid    int
+	pmtParsed bool
+	sections  map[int][]byte
+
+	streams []*elementaryStream
+	video   *elementaryStream
+
+	pes       []byte
+	videoInfo *videoInfo
+	pts       []int64
+}
+
+// streamLanguage ISO 639 language descriptor value
+func streamLanguage(descriptors []byte) string {
+	for len(descriptors) >= 2 {
+		length := int(descriptors[1])
+		if descriptors[0] == 0x0A && length >= 3 && len(descriptors) >= 5 {
+			return string(descriptors[2:5])
+		}
+		descriptors = descriptors[minInt(len(descriptors), 2+length):]
+	}
+	return ""
+}
+
+func streamCodec(streamType byte, descriptors []byte) (string, string) {
+	switch streamType {
+	case 0x01, 0x02:
+		return "video", "mpeg2video"
+	case 0x1B:
+		return "video", "h264"
+	case 0x24:
+		return "video", "hevc"
+	case 0x03, 0x04:
+		return "audio", "mp2"
+	case 0x0F:
+		return "audio", "aac"
+	case 0x11:
+		return "audio", "aac_latm"
+	case 0x81:
+		return "audio", "ac3"
+	case 0x87:
+		return "audio", "eac3"
+	case 0x06:
+		// PES private data, codec is defined by DVB descriptor
+		for len(descriptors) >= 2 {
+			switch descriptors[0] {
+			case 0x6A:
+				return "audio", "ac3"
+			case 0x7A:
+				return "audio", "eac3"
+			case 0x56:
+				return "subtitle", "dvb_teletext"
+			case 0x59:
+				return "subtitle", "dvb_subtitle"
+			}
+			descriptors = descriptors[minInt(len(descriptors), 2+int(descriptors[1])):]
+		}
+	}
+	return "data", ""
+}
+
+// Probe reads transport stream till video sequence header is found
+func Probe(r io.Reader) (*ffprobe.MetaData, error) {
+	br := bufio.NewReaderSize(r, 64*1024)
+	head, _ := br.Peek(16)
+	if bytes.HasPrefix(bytes.TrimPrefix(head, []byte{0xEF, 0xBB, 0xBF}), []byte("#EXTM3U")) {
+		return nil, ErrPlaylist
+	}
+
+	d := demuxer{pmtPid: -1, sections: map[int][]byte{}}
+	packet := make([]byte, packetSize)
+	for !d.completed() {
+		err := readPacket(br, packet)
+		if err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				break
+			}
+			return nil, err
+		}
+		d.packet(packet)
+	}
+	d.flushPes()
+
+	return d.metaData()
+}
+
+// readPacket reads next packet, resynchronizes on broken sync byte
+func readPacket(br *bufio.Reader, packet []byte) error {
+	for {
+		data, err := br.Peek(packetSize + 1)
+		if len(data) < packetSize {
+			if err == nil {
+				err = io.EOF
+			}
+			return err
+		}
+		// Next packet sync byte check filters sync byte values inside payload
+		if data[0] == syncByte && (len(data) == packetSize || data[packetSize] == syncByte) {
+			_, err = io.ReadFull(br, packet)
+			return err
+		}
+		_, _ = br.Discard(1)
+	}
+}
+
+func minInt(a int, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func (d *demuxer) completed() bool {
+	if !d.pmtParsed {
+		return false
+	}
+	if d.video == nil {
+		return true
+	}
+	if d.videoInfo == nil {
+		return false
+	}
+	return d.videoInfo.FrameRateNum > 0 || len(d.pts) >= ptsSamples
+}
+
+func (d *demuxer) packet(packet []byte) {
+	unitStart := packet[1]&0x40 != 0
+	pid := int(packet[1]&0x1F)<<8 | int(packet[2])
+	adaptation := (packet[3] >> 4) & 0x03
+
+	if adaptation&0x01 == 0 {
+		return
+	}
+	start := 4
+	if adaptation&0x02 != 0 {
+		start += 1 + int(packet[4])
+	}
+	if start >= packetSize {
+		return
+	}
+	payload := packet[start:]
+
+	switch {
+	case pid == patPid || (pid == d.pmtPid && !d.pmtParsed):
+		d.section(pid, unitStart, payload)
+	case d.video != nil && pid == d.video.Pid:
+		d.videoPayload(unitStart, payload)
+	}
+}
+
+// section collects PSI section which may span several packets
+func (d *demuxer) section(pid int, unitStart bool, payload []byte) {
+	if unitStart {
+		pointer := int(payload[0])
+		if 1+pointer >= len(payload) {
+			return
+		}
+		d.sections[pid] = append([]byte{}, payload[1+pointer:]...)
+	} else if buf, ok := d.sections[pid]; ok {
+		d.sections[pid] = append(buf, payload...)
+	} else {
+		return
+	}
+
+	buf := d.sections[pid]
+	if len(buf) < 3 {
+		return
+	}
+	length := 3 + (int(buf[1]&0x0F)<<8 | int(buf[2]))
+	if len(buf) < length {
+		return
+	}
+	delete(d.sections, pid)
+
+	// Skip 5 bytes of extended header, drop CRC32
+	if length < 12 {
+		return
+	}
+	switch buf[0] {
+	case 0x00:
+		d.pat(buf[8 : length-4])
+	case 0x02:
+		d.pmt(buf[:length-4])
+	}
+}
+
+func (d *demuxer) pat(entries []byte) {
+	for i := 0; i+4 <= len(entries); i += 4 {
+		program := int(entries[i])<<8 | int(entries[i+1])
+		if program == 0 {
+			// Network information table
+			continue
+		}
+		d.pmtPid = int(entries[i+2]&0x1F)<<8 | int(entries[i+3])
+		return
+	}
+}
+
+func (d *demuxer) pmt(section []byte) {
+	pos := 12 + (int(section[10]&0x0F)<<8 | int(section[11]))
+	for pos+5 <= len(section) {
+		streamType := section[pos]
+		pid := int(section[pos+1]&0x1F)<<8 | int(section[pos+2])
+		infoLength := int(section[pos+3]&0x0F)<<8 | int(section[pos+4])
+		end := minInt(len(section), pos+5+infoLength)
+
+		codecType, codecName := streamCodec(streamType, section[pos+5:end])
+		stream := &elementaryStream{Pid: pid, CodecType: codecType, CodecName: codecName, Language: streamLanguage(section[pos+5 : end])}
+		d.streams = append(d.streams, stream)
+		if codecType == "video" && d.video == nil {
+			d.video = stream
+		}
+		pos = end
+	}
+	d.pmtParsed = true
+}
+
+func (d *demuxer) videoPayload(unitStart bool, payload []byte) {
+	if unitStart {
+		d.flushPes()
+		if len(payload) < 9 || payload[0] != 0 || payload[1] != 0 || payload[2] != 1 {
+			return
+		}
+		headerLength := int(payload[8])
+		if payload[7]&0x80 != 0 && len(payload) >= 14 {
+			d.pts = append(d.pts, parseTimestamp(payload[9:14]))
+		}
+		if 9+headerLength > len(payload) {
+			return
+		}
+		payload = payload[9+headerLength:]
+	}
+	if d.videoInfo != nil || len(d.pes)+len(payload) > maxPesBuffer {
+		return
+	}
+	d.pes = append(d.pes, payload...)
+}
+
+// flushPes looks for sequence header in collected PES payload
+func (d *demuxer) flushPes() {
+	if d.video != nil && d.videoInfo == nil && len(d.pes) > 0 {
+		d.videoInfo = findSequence(d.video.CodecName, d.pes)
+	}
+	d.pes = d.pes[:0]
+}
+
+func parseTimestamp(data []byte) int64 {
+	return int64(data[0]&0x0E)<<29 | int64(data[1])<<22 | int64(data[2]&0xFE)<<14 | int64(data[3])<<7 | int64(data[4])>>1
+}
+
+// findSequence parses first sequence header of Annex B byte stream
+func findSequence(codec string, data []byte) *videoInfo {
+	units := splitUnits(data)
+	for i, unit := range units {
+		if len(unit) < 2 {
+			continue
+		}
+		var info *videoInfo
+		var err error
+		switch codec {
+		case "h264":
+			if unit[0]&0x1F == 7 {
+				info, err = parseH264Sps(unit[1:])
+			}
+		case "hevc":
+			if (unit[0]>>1)&0x3F == 33 && len(unit) > 2 {
+				info, err = parseHevcSps(unit[2:])
+			}
+		case "mpeg2video":
+			if unit[0] == 0xB3 {
+				info, err = parseMpeg2Sequence(unit[1:])
+				if err == nil {
+					for _, ext := range units[i+1:] {
+						if len(ext) > 1 && ext[0] == 0xB5 {
+							parseMpeg2Extension(ext[1:], info)
+							break
+						}
+					}
+				}
+			}
+		}
+		if err == nil && info != nil && info.Width > 0 && info.Height > 0 {
+			return info
+		}
+	}
+	return nil
+}
+
+// splitUnits splits byte stream by 00 00 01 start codes
+func splitUnits(data []byte) [][]byte {
+	var units [][]byte
+	start := -1
+	for i := 0; i+2 < len(data); i++ {
+		if data[i] != 0 || data[i+1] != 0 || data[i+2] != 1 {
+			continue
+		}
+		if start >= 0 {
+			units = append(units, bytes.TrimRight(data[start:i], "\x00"))
+		}
+		start = i + 3
+		i += 2
+	}
+	if start >= 0 && start < len(data) {
+		units = append(units, data[start:])
+	}
+	return units
+}
+
+// ptsFrameRate most common timestamp delta in 90 kHz clock
+func (d *demuxer) ptsFrameRate() (int, int) {
+	pts := append([]int64{}, d.pts...)
+	sort.Slice(pts, func(i, j int) bool { return pts[i] < pts[j] })
+
+	counts := map[int64]int{}
+	var best int64
+	for i := 1; i < len(pts); i++ {
+		delta := pts[i] - pts[i-1]
+		if delta <= 0 {
+			continue
+		}
+		counts[delta]++
+		if counts[delta] > counts[best] || (counts[delta] == counts[best] && delta < best) {
+			best = delta
+		}
+	}
+	if best == 0 {
+		return 0, 0
+	}
+	return 90000, int(best)
+}
+
+func (d *demuxer) metaData() (*ffprobe.MetaData, error) {
+	if !d.pmtParsed {
+		return nil, ErrNoProgram
+	}
+	if d.video != nil && d.videoInfo == nil {
+		return nil, ErrNoSequence
+	}
+
+	metaData := &ffprobe.MetaData{Format: ffprobe.FormatData{Format: "mpegts"}}
+	for i, stream := range d.streams {
+		data := ffprobe.StreamData{
+			Index:     i,
+			CodecType: stream.CodecType,
+			CodecName: stream.CodecName,
+		}
+		if stream.Language != "" {
+			data.Tags = map[string]string{"language": stream.Language}
+		}
+		if stream == d.video {
+			info := *d.videoInfo
+			if info.FrameRateNum == 0 {
+				info.setFrameRate(d.ptsFrameRate())
+			}
+			data.Width = info.Width
+			data.Height = info.Height
+			data.Profile = info.Profile
+			data.Level = info.Level
+			data.PixFmt = info.PixFmt
+			data.FieldOrder = info.FieldOrder
+			if info.FrameRateNum > 0 {
+				data.RFrameRate = ffprobe.NewFraction(info.FrameRateNum, info.FrameRateDen)
+				data.AVGFrameRate = data.RFrameRate
+			}
+		}
+		metaData.Streams = append(metaData.Streams, data)
+	}
+	return metaData, nil
+}
@@ -0,0 +1,377 @@
+package tsprobe
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+type bitWriter struct {
+	data []byte
+	bits int
+}
+
+func (w *bitWriter) u(n int, v uint32) {
+	for i := n - 1; i >= 0; i-- {
+		if w.bits%8 == 0 {
+			w.data = append(w.data, 0)
+		}
+		if (v>>uint(i))&1 == 1 {
+			w.data[len(w.data)-1] |= 1 << uint(7-w.bits%8)
+		}
+		w.bits++
+	}
+}
+
+func (w *bitWriter) flag(v bool) {
+	if v {
+		w.u(1, 1)
+	} else {
+		w.u(1, 0)
+	}
+}
+
+func (w *bitWriter) ue(v uint32) {
+	v++
+	n := 0
+	for t := v; t > 1; t >>= 1 {
+		n++
+	}
+	w.u(n, 0)
+	w.u(n+1, v)
+}
+
+func (w *bitWriter) se(v int32) {
+	if v > 0 {
+		w.ue(uint32(2*v - 1))
+	} else {
+		w.ue(uint32(-2 * v))
+	}
+}
+
+// bytes closes rbsp with stop bit and inserts emulation prevention bytes
+func (w *bitWriter) bytes() []byte {
+	w.u(1, 1)
+	out := []byte{}
+	zeros := 0
+	for _, b := range w.data {
+		if zeros >= 2 && b <= 3 {
+			out = append(out, 3)
+			zeros = 0
+		}
+		out = append(out, b)
+		if b == 0 {
+			zeros++
+		} else {
+			zeros = 0
+		}
+	}
+	return out
+}
+
+// h264Sps High profile 1920x1080 with cropping, timing info is optional
+func h264Sps(timing bool) []byte {
+	w := &bitWriter{}
+	w.u(8, 100) // profile_idc
+	w.u(16, 40) // constraint flags, level_idc
+	w.ue(0)     // sps id
+	w.ue(1)     // chroma_format_idc 4:2:0
+	w.ue(0)
+	w.ue(0)
+	w.flag(false)
+	w.flag(true) // seq_scaling_matrix_present_flag
+	for i := 0; i < 8; i++ {
+		w.flag(i == 0)
+		if i == 0 {
+			for j := 0; j < 16; j++ {
+				w.se(1)
+			}
+		}
+	}
+	w.ue(0) // log2_max_frame_num_minus4
+	w.ue(0) // pic_order_cnt_type
+	w.ue(2)
+	w.ue(4) // max_num_ref_frames
+	w.flag(false)
+	w.ue(119) // 120 mbs width
+	w.ue(67)  // 68 mbs height
+	w.flag(true)
+	w.flag(true)
+	w.flag(true) // frame_cropping_flag
+	w.ue(0)
+	w.ue(0)
+	w.ue(0)
+	w.ue(4)
+	w.flag(timing) // vui_parameters_present_flag
+	if timing {
+		w.flag(true) // aspect_ratio_info_present_flag
+		w.u(8, 1)
+		w.flag(false)
+		w.flag(true) // video_signal_type_present_flag
+		w.u(4, 5)
+		w.flag(true)
+		w.u(24, 0x010101)
+		w.flag(false)
+		w.flag(true) // timing_info_present_flag
+		w.u(32, 1)
+		w.u(32, 50)
+		w.flag(true)
+	}
+	return append([]byte{0x67}, w.bytes()...)
+}
+
+// hevcSps Main profile 3840x2160 with 29.97 fps timing
+func hevcSps() []byte {
+	w := &bitWriter{}
+	w.u(4, 0)
+	w.u(3, 1) // sps_max_sub_layers_minus1
+	w.flag(true)
+	w.u(32, 0x01600000)
+	w.u(32, 0)
+	w.u(24, 0)
+	w.u(8, 153)
+	w.flag(true) // sub_layer_profile_present_flag
+	w.flag(true) // sub_layer_level_present_flag
+	for i := 1; i < 8; i++ {
+		w.u(2, 0)
+	}
+	w.u(32, 0)
+	w.u(32, 0)
+	w.u(24, 0)
+	w.u(8, 120)
+	w.ue(0) // sps id
+	w.ue(1) // chroma_format_idc
+	w.ue(3840)
+	w.ue(2176)
+	w.flag(true) // conformance_window_flag
+	w.ue(0)
+	w.ue(0)
+	w.ue(0)
+	w.ue(8)
+	w.ue(0)
+	w.ue(0)
+	w.ue(4)       // log2_max_pic_order_cnt_lsb_minus4
+	w.flag(false) // sub layer ordering info for highest layer only
+	w.ue(4)
+	w.ue(2)
+	w.ue(0)
+	for i := 0; i < 6; i++ {
+		w.ue(1)
+	}
+	w.flag(true) // scaling_list_enabled_flag
+	w.flag(true) // sps_scaling_list_data_present_flag
+	for sizeId := 0; sizeId < 4; sizeId++ {
+		step := 1
+		if sizeId == 3 {
+			step = 3
+		}
+		for matrixId := 0; matrixId < 6; matrixId += step {
+			if sizeId != 2 {
+				w.flag(false)
+				w.ue(0)
+				continue
+			}
+			w.flag(true)
+			w.se(-8)
+			for i := 0; i < 64; i++ {
+				w.se(0)
+			}
+		}
+	}
+	w.flag(true)
+	w.flag(true)
+	w.flag(false) // pcm_enabled_flag
+	w.ue(2)       // num_short_term_ref_pic_sets
+	w.ue(2)
+	w.ue(1)
+	for i := 0; i < 3; i++ {
+		w.ue(0)
+		w.flag(true)
+	}
+	w.flag(true) // inter_ref_pic_set_prediction_flag
+	w.flag(false)
+	w.ue(0)
+	for i := 0; i <= 3; i++ {
+		w.flag(i != 1)
+		if i == 1 {
+			w.flag(true)
+		}
+	}
+	w.flag(true) // long_term_ref_pics_present_flag
+	w.ue(1)
+	w.u(9, 0)
+	w.flag(true)
+	w.flag(true)
+	w.flag(true) // vui_parameters_present_flag
+	w.flag(false)
+	w.flag(false)
+	w.flag(false)
+	w.flag(false)
+	w.u(3, 0)
+	w.flag(false)
+	w.flag(true) // vui_timing_info_present_flag
+	w.u(32, 1001)
+	w.u(32, 30000)
+	return append([]byte{0x42, 0x01}, w.bytes()...)
+}
+
+func TestParseSequence(t *testing.T) {
+	sps := h264Sps(true)
+	info, err := parseH264Sps(sps[1:])
+	if err != nil {
+		t.Fatalf("parseH264Sps failed: %v", err)
+	}
+	if info.Width != 1920 || info.Height != 1080 || info.FrameRateNum != 25 || info.FrameRateDen != 1 {
+		t.Fatalf("unexpected h264 info: %+v", info)
+	}
+
+	sps = hevcSps()
+	info, err = parseHevcSps(sps[2:])
+	if err != nil {
+		t.Fatalf("parseHevcSps failed: %v", err)
+	}
+	if info.Width != 3840 || info.Height != 2160 || info.FrameRateNum != 30000 || info.FrameRateDen != 1001 {
+		t.Fatalf("unexpected hevc info: %+v", info)
+	}
+
+	// Corrupt set count above spec limit must be rejected before allocation
+	if skipHevcShortTermRefPicSets(newBitReader(nil), 1<<31) {
+		t.Fatalf("short term ref pic sets count above limit must fail")
+	}
+
+	info, err = parseMpeg2Sequence([]byte{0x2D, 0x02, 0x40, 0x23, 0xFF, 0xFF})
+	if err != nil {
+		t.Fatalf("parseMpeg2Sequence failed: %v", err)
+	}
+	if info.Width != 720 || info.Height != 576 || info.FrameRateNum != 25 {
+		t.Fatalf("unexpected mpeg2 info: %+v", info)
+	}
+	// Sequence extension: Main profile, Main level, interlaced 4:2:0
+	parseMpeg2Extension([]byte{0x14, 0x82, 0x00, 0x01}, info)
+	if info.Profile != "Main" || info.Level != 8 || info.FieldOrder != "tt" || info.PixFmt != "yuv420p" {
+		t.Fatalf("unexpected mpeg2 extension info: %+v", info)
+	}
+}
+
+type tsWriter struct {
+	buf        bytes.Buffer
+	continuity map[int]byte
+}
+
+func (w *tsWriter) write(pid int, data []byte) {
+	unitStart := true
+	for len(data) > 0 {
+		packet := []byte{syncByte, byte(pid >> 8), byte(pid), 0x10 | w.continuity[pid]&0x0F}
+		w.continuity[pid]++
+		if unitStart {
+			packet[1] |= 0x40
+			unitStart = false
+		}
+		n := packetSize - len(packet)
+		if len(data) < n {
+			// Adaptation field stuffing
+			stuffing := n - len(data) - 1
+			packet[3] |= 0x20
+			packet = append(packet, byte(stuffing))
+			if stuffing > 0 {
+				packet = append(packet, 0x00)
+				packet = append(packet, bytes.Repeat([]byte{0xFF}, stuffing-1)...)
+			}
+			n = len(data)
+		}
+		packet = append(packet, data[:n]...)
+		data = data[n:]
+		w.buf.Write(packet)
+	}
+}
+
+func (w *tsWriter) section(pid int, tableId byte, body []byte) {
+	length := 5 + len(body) + 4
+	section := []byte{0x00, tableId, 0xB0 | byte(length>>8), byte(length), 0x00, 0x01, 0xC1, 0x00, 0x00}
+	section = append(section, body...)
+	section = append(section, 0, 0, 0, 0) // CRC is not validated
+	w.write(pid, section)
+}
+
+func (w *tsWriter) pes(pid int, pts int64, payload []byte) {
+	header := []byte{0x00, 0x00, 0x01, 0xE0, 0x00, 0x00, 0x80, 0x80, 0x05,
+		byte(0x21 | (pts>>29)&0x0E), byte(pts >> 22), byte(0x01 | (pts>>14)&0xFE), byte(pts >> 7), byte(0x01 | (pts<<1)&0xFE)}
+	w.write(pid, append(header, payload...))
+}
+
+func buildStream(sps []byte, frames int) []byte {
+	w := &tsWriter{continuity: map[int]byte{}}
+	// Garbage before first packet requires resync
+	w.buf.Write([]byte{0x00, syncByte, 0x12})
+
+	w.section(0, 0x00, []byte{0x00, 0x01, 0xF0, 0x00})
+	w.section(0x1000, 0x02, []byte{
+		0xE1, 0x00, 0xF0, 0x00,
+		0x1B, 0xE1, 0x00, 0xF0, 0x00,
+		0x0F, 0xE1, 0x01, 0xF0, 0x00,
+		0x06, 0xE1, 0x02, 0xF0, 0x09, 0x6A, 0x01, 0x00, 0x0A, 0x04, 'e', 'n', 'g', 0x00,
+	})
+
+	for i := 0; i < frames; i++ {
+		payload := []byte{0x00, 0x00, 0x00, 0x01, 0x09, 0xF0}
+		if i == 0 {
+			payload = append(payload, 0x00, 0x00, 0x01)
+			payload = append(payload, sps...)
+			payload = append(payload, 0x00, 0x00, 0x01, 0x68, 0xEE, 0x3C, 0x80)
+		}
+		payload = append(payload, 0x00, 0x00, 0x01, 0x65)
+		payload = append(payload, bytes.Repeat([]byte{0xAB}, 400)...)
+		// B-frames reorder presentation timestamps
+		pts := int64(900000 + 3600*i)
+		if i%2 == 1 {
+			pts += 3600
+		} else if i > 0 {
+			pts -= 3600
+		}
+		w.pes(0x100, pts, payload)
+		w.pes(0x101, pts, bytes.Repeat([]byte{0xCD}, 200))
+	}
+	return w.buf.Bytes()
+}
+
+func TestProbe(t *testing.T) {
+	metaData, err := Probe(bytes.NewReader(buildStream(h264Sps(true), 4)))
+	if err != nil {
+		t.Fatalf("Probe failed: %v", err)
+	}
+	if len(metaData.Streams) != 3 {
+		t.Fatalf("expected 3 streams, got %+v", metaData.Streams)
+	}
+	video := metaData.GetVideoStream()
+	if video == nil || video.CodecName != "h264" || video.Width != 1920 || video.Height != 1080 || video.RFrameRate.Value != "25/1" {
+		t.Fatalf("unexpected video stream: %+v", video)
+	}
+	if video.Profile != "High" || video.Level != 40 || video.PixFmt != "yuv420p" || video.FieldOrder != "progressive" || video.IsInterlaced() {
+		t.Fatalf("unexpected video details: %+v", video)
+	}
+	if metaData.Streams[1].CodecType != "audio" || metaData.Streams[1].CodecName != "aac" || metaData.Streams[2].CodecName != "ac3" {
+		t.Fatalf("unexpected audio streams: %+v", metaData.Streams)
+	}
+	if metaData.Streams[1].Language() != "" || metaData.Streams[2].Language() != "eng" {
+		t.Fatalf("unexpected audio languages: %+v", metaData.Streams)
+	}
+
+	// No VUI timing, frame rate is taken from PES timestamps
+	metaData, err = Probe(bytes.NewReader(buildStream(h264Sps(false), 20)))
+	if err != nil {
+		t.Fatalf("Probe without timing failed: %v", err)
+	}
+	if video = metaData.GetVideoStream(); video.RFrameRate.Value != "25/1" {
+		t.Fatalf("unexpected pts frame rate: %+v", video.RFrameRate)
+	}
+
+	_, err = Probe(bytes.NewReader(buildStream(nil, 2)))
+	if err != ErrNoSequence {
+		t.Fatalf("expected ErrNoSequence, got %v", err)
+	}
+
+	_, err = Probe(strings.NewReader("#EXTM3U\n#EXT-X-TARGETDURATION:10\n"))
+	if err != ErrPlaylist {
+		t.Fatalf("expected ErrPlaylist, got %v", err)
+	}
+}
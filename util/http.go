@@ -2,14 +2,53 @@ package util
 
 import (
 	"bytes"
+	"context"
 	"encoding/base64"
+	"fmt"
 	"net/http"
 	"net/url"
 	"time"
 )
 
+// HTTPStatusError non 2xx response status
+type HTTPStatusError struct {
+	StatusCode int
+	Status     string
+}
+
+func (e *HTTPStatusError) Error() string {
+	if e.Status == "" {
+		return fmt.Sprintf("unexpected status: %d", e.StatusCode)
+	}
+	return "unexpected status: " + e.Status
+}
+
+// CheckHTTPStatus returns HTTPStatusError for non 2xx response
+func CheckHTTPStatus(resp *http.Response) error {
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return &HTTPStatusError{StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+	return nil
+}
+
 func MakeHTTPRequest(method string, url string, headers map[string]string, values *url.Values, data []byte, timeoutSeconds uint32) (*http.Response, error) {
-	req, err := http.NewRequest(method, url, bytes.NewReader(data))
+	return MakeHTTPRequestContext(context.Background(), method, url, headers, values, data, timeoutSeconds)
+}
+
+func MakeHTTPRequestContext(ctx context.Context, method string, url string, headers map[string]string, values *url.Values, data []byte, timeoutSeconds uint32) (*http.Response, error) {
+	return MakeHTTPRequestTimeout(ctx, method, url, headers, values, data, time.Second*time.Duration(timeoutSeconds))
+}
+
+// httpTransport shared by all requests, idle keep-alive connections are reused and closed after timeout
+var httpTransport = &http.Transport{
+	//TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+	MaxIdleConns:    100,
+	IdleConnTimeout: 90 * time.Second,
+}
+
+// MakeHTTPRequestTimeout request with timeout of whole exchange, zero timeout means no timeout
+func MakeHTTPRequestTimeout(ctx context.Context, method string, url string, headers map[string]string, values *url.Values, data []byte, timeout time.Duration) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(data))
 	if err != nil {
 		return nil, err
 	}
@@ -24,12 +63,7 @@ func MakeHTTPRequest(method string, url string, headers map[string]string, value
 		req.URL.RawQuery = values.Encode()
 	}
 
-	tr := &http.Transport{
-		//TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-	}
-
-	client := http.Client{Transport: tr}
-	client.Timeout = time.Second * time.Duration(timeoutSeconds)
+	client := http.Client{Transport: httpTransport, Timeout: timeout}
 	return client.Do(req)
 }
 
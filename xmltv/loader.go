@@ -44,12 +44,13 @@ type XmlProgramme struct {
 	Channel     string `xml:"channel,attr"`
 	Title       string `xml:"title"`
 	Description string `xml:"desc"`
+	// Ratings age ratings, e.g. <rating system="RU"><value>18+</value></rating>
+	Ratings []XmlRating `xml:"rating"`
 
 	//SubTitle    string   `xml:"sub-title"`
 	//Credits     string   `xml:"credits"`
 	//Date        string   `xml:"date"`
 	//Categories  []string `xml:"category"`
-	//Rating      string   `xml:"rating>value"`
 
 	start time.Time
 	stop  time.Time
@@ -64,6 +65,11 @@ func (x *XmlProgramme) Init() {
 	// x.Stop = x.start.Format(xmldateformat)
 }
 
+type XmlRating struct {
+	System string `xml:"system,attr,omitempty"`
+	Value  string `xml:"value"`
+}
+
 type TvgChannel struct {
 	dbChannel *db.TvgChannel
 	Channel   *XmlChannel
@@ -363,7 +369,7 @@ func ExtractTarGz(outFileName string) error {
 			outFile.Close()
 
 		default:
-			return fmt.Errorf("ExtractTarGz: uknown type: %s in %s", header.Typeflag, header.Name)
+			return fmt.Errorf("ExtractTarGz: uknown type: %c in %s", header.Typeflag, header.Name)
 		}
 
 	}
@@ -379,20 +385,20 @@ func ExtractGz(inFileName string, outFileName string) error {
 	gzipfile, err := os.Open(inFileName)
 
 	if err != nil {
-		return fmt.Errorf("file open error %v")
+		return fmt.Errorf("file open error %v", err)
 	}
 
 	reader, err := gzip.NewReader(gzipfile)
 
 	if err != nil {
-		return fmt.Errorf("gzip.NewReader error %v")
+		return fmt.Errorf("gzip.NewReader error %v", err)
 	}
 	defer reader.Close()
 
 	writer, err := os.Create(outFileName)
 
 	if err != nil {
-		return fmt.Errorf("output file create error %v")
+		return fmt.Errorf("output file create error %v", err)
 	}
 
 	defer writer.Close()
@@ -0,0 +1,147 @@
+package xmltv
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// ratingAges minimum viewer age of known rating systems without numeric values
+var ratingAges = map[string]int{
+	"G": 0, "PG": 10, "PG-13": 13, "R": 17, "NC-17": 18,
+	"TV-Y": 0, "TV-Y7": 7, "TV-G": 0, "TV-PG": 10, "TV-14": 14, "TV-MA": 17,
+	"XXX": 18,
+}
+
+// RatingAge minimum viewer age of rating value: "18+" -> 18, "TV-MA" -> 17
+func RatingAge(value string) (int, bool) {
+	value = strings.ToUpper(strings.TrimSpace(value))
+	if age, ok := ratingAges[value]; ok {
+		return age, true
+	}
+	digits := strings.TrimRightFunc(value, func(r rune) bool { return !unicode.IsDigit(r) })
+	digits = strings.TrimLeftFunc(digits, func(r rune) bool { return !unicode.IsDigit(r) })
+	age, err := strconv.Atoi(digits)
+	if err != nil {
+		return 0, false
+	}
+	return age, true
+}
+
+// ChannelRatings programme airtime of channel by age rating, unrated programmes count in total airtime only
+type ChannelRatings struct {
+	Airtime time.Duration
+	ByAge   map[int]time.Duration
+}
+
+// Share part of airtime rated at least minAge
+func (r *ChannelRatings) Share(minAge int) float64 {
+	if r == nil || r.Airtime <= 0 {
+		return 0
+	}
+	var rated time.Duration
+	for age, airtime := range r.ByAge {
+		if age >= minAge {
+			rated += airtime
+		}
+	}
+	return float64(rated) / float64(r.Airtime)
+}
+
+func (r *ChannelRatings) add(p *XmlProgramme) {
+	airtime := p.stop.Sub(p.start)
+	if airtime <= 0 {
+		return
+	}
+	r.Airtime += airtime
+	age, rated := -1, false
+	for _, rating := range p.Ratings {
+		if value, ok := RatingAge(rating.Value); ok && value > age {
+			age, rated = value, true
+		}
+	}
+	if rated {
+		r.ByAge[age] += airtime
+	}
+}
+
+// Ratings programme airtime by age rating of guide channels by id and display name
+type Ratings struct {
+	byId   map[string]*ChannelRatings
+	byName map[string]*ChannelRatings
+}
+
+// Channel ratings of guide channel id or first known display name, nil if channel is unknown
+func (r *Ratings) Channel(id string, names ...string) *ChannelRatings {
+	if r == nil {
+		return nil
+	}
+	if ratings, ok := r.byId[id]; ok && id != "" {
+		return ratings
+	}
+	for _, name := range names {
+		if ratings, ok := r.byName[name]; ok && name != "" {
+			return ratings
+		}
+	}
+	return nil
+}
+
+// LoadRatings reads guide file and returns programme airtime by age rating of its channels
+func LoadRatings(fileName string) (*Ratings, error) {
+	f, err := os.Open(fileName)
+	if f != nil {
+		defer f.Close()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("open file error: %v", err)
+	}
+
+	names := map[string][]string{}
+	ratings := map[string]*ChannelRatings{}
+	decoder := xml.NewDecoder(f)
+	for {
+		t, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read guide: %v", err)
+		}
+		se, ok := t.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch se.Name.Local {
+		case "channel":
+			var c XmlChannel
+			if err = decoder.DecodeElement(&c, &se); err != nil {
+				return nil, fmt.Errorf("failed DecodeElement channel: %v", err)
+			}
+			names[c.Id] = c.Name
+		case "programme":
+			var p XmlProgramme
+			if err = decoder.DecodeElement(&p, &se); err != nil {
+				return nil, fmt.Errorf("failed DecodeElement programme: %v", err)
+			}
+			p.Init()
+			if ratings[p.Channel] == nil {
+				ratings[p.Channel] = &ChannelRatings{ByAge: map[int]time.Duration{}}
+			}
+			ratings[p.Channel].add(&p)
+		}
+	}
+
+	result := &Ratings{byId: ratings, byName: map[string]*ChannelRatings{}}
+	for id, channelRatings := range ratings {
+		for _, name := range names[id] {
+			result.byName[name] = channelRatings
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,200 @@
+package xtream
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"m3u8/util"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FlexString panels return ids both as numbers and strings
+type FlexString string
+
+func (f *FlexString) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		*f = ""
+		return nil
+	}
+	var str string
+	if err := json.Unmarshal(data, &str); err == nil {
+		*f = FlexString(str)
+		return nil
+	}
+	var num json.Number
+	if err := json.Unmarshal(data, &num); err != nil {
+		return err
+	}
+	*f = FlexString(num.String())
+	return nil
+}
+
+// FlexInt panels return numbers both as numbers and strings
+type FlexInt int
+
+func (f *FlexInt) UnmarshalJSON(data []byte) error {
+	var str FlexString
+	if err := json.Unmarshal(data, &str); err != nil {
+		return err
+	}
+	if str == "" {
+		*f = 0
+		return nil
+	}
+	val, err := strconv.ParseFloat(string(str), 64)
+	if err != nil {
+		return err
+	}
+	*f = FlexInt(val)
+	return nil
+}
+
+type Category struct {
+	Id       FlexString `json:"category_id"`
+	Name     string     `json:"category_name"`
+	ParentId FlexInt    `json:"parent_id"`
+}
+
+type Stream struct {
+	Num               FlexInt    `json:"num"`
+	Name              string     `json:"name"`
+	StreamType        string     `json:"stream_type"`
+	StreamId          FlexInt    `json:"stream_id"`
+	StreamIcon        string     `json:"stream_icon"`
+	EpgChannelId      string     `json:"epg_channel_id"`
+	CategoryId        FlexString `json:"category_id"`
+	TvArchive         FlexInt    `json:"tv_archive"`
+	TvArchiveDuration FlexInt    `json:"tv_archive_duration"`
+	DirectSource      string     `json:"direct_source"`
+}
+
+type EpgListing struct {
+	Id             FlexString `json:"id"`
+	EpgId          FlexString `json:"epg_id"`
+	Title          string     `json:"title"`
+	Description    string     `json:"description"`
+	Lang           string     `json:"lang"`
+	Start          string     `json:"start"`
+	End            string     `json:"end"`
+	ChannelId      string     `json:"channel_id"`
+	StartTimestamp FlexInt    `json:"start_timestamp"`
+	StopTimestamp  FlexInt    `json:"stop_timestamp"`
+}
+
+// DecodedTitle epg titles and descriptions are base64 encoded
+func (e *EpgListing) DecodedTitle() string {
+	return decodeBase64(e.Title)
+}
+
+func (e *EpgListing) DecodedDescription() string {
+	return decodeBase64(e.Description)
+}
+
+func (e *EpgListing) StartTime() time.Time {
+	return time.Unix(int64(e.StartTimestamp), 0)
+}
+
+func (e *EpgListing) StopTime() time.Time {
+	return time.Unix(int64(e.StopTimestamp), 0)
+}
+
+func decodeBase64(value string) string {
+	data, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return value
+	}
+	return string(data)
+}
+
+type shortEpg struct {
+	Listings []EpgListing `json:"epg_listings"`
+}
+
+// Client Xtream Codes player_api.php client
+type Client struct {
+	// Url panel base url: http://panel.example.com:8080
+	Url      string
+	Username string
+	Password string
+
+	TimeoutSeconds uint32
+}
+
+func NewClient(panelUrl string, username string, password string) *Client {
+	return &Client{
+		Url:            strings.TrimRight(panelUrl, "/"),
+		Username:       username,
+		Password:       password,
+		TimeoutSeconds: 30,
+	}
+}
+
+func (c *Client) query(action string, params map[string]string, result interface{}) error {
+	values := url.Values{}
+	values.Set("username", c.Username)
+	values.Set("password", c.Password)
+	values.Set("action", action)
+	for k, v := range params {
+		values.Set(k, v)
+	}
+
+	resp, err := util.MakeHTTPRequest("GET", c.Url+"/player_api.php", nil, &values, nil, c.TimeoutSeconds)
+	if err != nil {
+		return fmt.Errorf("%s request failed: %v", action, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("%s request failed with status: %s", action, resp.Status)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("%s response read failed: %v", action, err)
+	}
+
+	err = json.Unmarshal(data, result)
+	if err != nil {
+		return fmt.Errorf("%s response unmarshall failed: %v", action, err)
+	}
+	return nil
+}
+
+func (c *Client) GetLiveCategories() ([]Category, error) {
+	var categories []Category
+	err := c.query("get_live_categories", nil, &categories)
+	return categories, err
+}
+
+// GetLiveStreams returns streams of category, all streams for empty categoryId
+func (c *Client) GetLiveStreams(categoryId string) ([]Stream, error) {
+	var params map[string]string
+	if categoryId != "" {
+		params = map[string]string{"category_id": categoryId}
+	}
+	var streams []Stream
+	err := c.query("get_live_streams", params, &streams)
+	return streams, err
+}
+
+func (c *Client) GetShortEpg(streamId int, limit int) ([]EpgListing, error) {
+	params := map[string]string{"stream_id": strconv.Itoa(streamId)}
+	if limit > 0 {
+		params["limit"] = strconv.Itoa(limit)
+	}
+	var epg shortEpg
+	err := c.query("get_short_epg", params, &epg)
+	return epg.Listings, err
+}
+
+// StreamUrl live stream url with output format: "ts" or "m3u8"
+func (c *Client) StreamUrl(streamId int, format string) string {
+	if format == "" {
+		format = "ts"
+	}
+	return fmt.Sprintf("%s/live/%s/%s/%d.%s", c.Url, url.PathEscape(c.Username), url.PathEscape(c.Password), streamId, format)
+}
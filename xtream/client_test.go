@@ -0,0 +1,121 @@
+package xtream
+
+import (
+	"m3u8/meta"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newFakePanel(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/player_api.php" {
+			http.NotFound(w, r)
+			return
+		}
+		query := r.URL.Query()
+		if query.Get("username") != "user" || query.Get("password") != "pass" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		switch query.Get("action") {
+		case "get_live_categories":
+			_, _ = w.Write([]byte(`[{"category_id":"1","category_name":"Кино","parent_id":0},{"category_id":2,"category_name":"Спорт","parent_id":"0"}]`))
+		case "get_live_streams":
+			_, _ = w.Write([]byte(`[
+{"num":2,"name":"Sport 1","stream_type":"live","stream_id":"205","stream_icon":"","epg_channel_id":null,"category_id":2,"tv_archive":0,"tv_archive_duration":0},
+{"num":"1","name":"Kino HD","stream_type":"live","stream_id":101,"stream_icon":"http://logo/kino.png","epg_channel_id":"kino.hd","category_id":"1","tv_archive":1,"tv_archive_duration":"3"}
+]`))
+		case "get_short_epg":
+			if query.Get("stream_id") != "101" || query.Get("limit") != "1" {
+				t.Errorf("unexpected epg query: %s", r.URL.RawQuery)
+			}
+			_, _ = w.Write([]byte(`{"epg_listings":[{"id":"1","epg_id":"7","title":"0J3QvtCy0L7RgdGC0Lg=","description":"","start_timestamp":"1700000000","stop_timestamp":1700003600}]}`))
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+}
+
+func TestLoadRecords(t *testing.T) {
+	server := newFakePanel(t)
+	defer server.Close()
+
+	client := NewClient(server.URL+"/", "user", "pass")
+	records, err := client.LoadRecords("m3u8", DefaultRemoteIdPrefix, 0)
+	if err != nil {
+		t.Fatalf("LoadRecords failed: %v", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("expected 2 records, got %d", len(records))
+	}
+
+	kino := records[0]
+	if kino.GroupName != "Кино" || kino.RemoteId != "xtream:101" || kino.Url != server.URL+"/live/user/pass/101.m3u8" {
+		t.Fatalf("unexpected record: %+v", kino)
+	}
+	extInf := meta.ParseExtInf(kino.NameData)
+	if extInf.Title != "Kino HD" {
+		t.Fatalf("unexpected title %s", extInf.Title)
+	}
+	for key, value := range map[string]string{
+		"tvg-id":       "kino.hd",
+		"tvg-logo":     "http://logo/kino.png",
+		"group-title":  "Кино",
+		"catchup":      "xc",
+		"catchup-days": "3",
+	} {
+		if v := extInf.Attributes.Value(key); v != value {
+			t.Fatalf("attribute %s: expected %s, got %s", key, value, v)
+		}
+	}
+
+	sport := records[1]
+	if sport.GroupName != "Спорт" || sport.RemoteId != "xtream:205" {
+		t.Fatalf("unexpected record: %+v", sport)
+	}
+	if extInf = meta.ParseExtInf(sport.NameData); extInf.Attributes.Has("catchup") || extInf.Attributes.Has("tvg-id") {
+		t.Fatalf("unexpected attributes: %s", extInf.Attributes.String())
+	}
+
+	_, err = NewClient(server.URL, "user", "wrong").LoadRecords("ts", "", 0)
+	if err == nil {
+		t.Fatalf("expected error for invalid credentials")
+	}
+}
+
+func TestGetShortEpg(t *testing.T) {
+	server := newFakePanel(t)
+	defer server.Close()
+
+	listings, err := NewClient(server.URL, "user", "pass").GetShortEpg(101, 1)
+	if err != nil {
+		t.Fatalf("GetShortEpg failed: %v", err)
+	}
+	if len(listings) != 1 {
+		t.Fatalf("expected 1 listing, got %d", len(listings))
+	}
+	listing := listings[0]
+	if listing.DecodedTitle() != "Новости" {
+		t.Fatalf("unexpected title %s", listing.DecodedTitle())
+	}
+	if listing.StopTime().Sub(listing.StartTime()).Hours() != 1 {
+		t.Fatalf("unexpected duration: %v - %v", listing.StartTime(), listing.StopTime())
+	}
+}
+
+func TestLoadMediaShortEpg(t *testing.T) {
+	server := newFakePanel(t)
+	defer server.Close()
+
+	// Stream without guide id is not requested
+	media, err := LoadMedia(NewClient(server.URL, "user", "pass"), "ts", DefaultRemoteIdPrefix, 1, nil)
+	if err != nil {
+		t.Fatalf("LoadMedia failed: %v", err)
+	}
+	_, kino, _ := media.FindChannel("Kino HD")
+	if kino == nil || len(kino.Programmes) != 1 || kino.Programmes[0].Title != "Новости" {
+		t.Fatalf("short epg must reach channel: %+v", kino)
+	}
+}
@@ -0,0 +1,99 @@
+package xtream
+
+import (
+	log "github.com/sirupsen/logrus"
+	"m3u8/meta"
+	"sort"
+	"strconv"
+)
+
+// DefaultRemoteIdPrefix keeps panel stream ids apart from other providers remote ids
+const DefaultRemoteIdPrefix = "xtream:"
+
+// LoadRecords builds playlist records from panel categories and live streams,
+// epgLimit upcoming programmes of streams with guide id are loaded if not zero
+func (c *Client) LoadRecords(format string, remoteIdPrefix string, epgLimit int) ([]*meta.Record, error) {
+	categories, err := c.GetLiveCategories()
+	if err != nil {
+		return nil, err
+	}
+	categoryNames := make(map[string]string, len(categories))
+	for _, category := range categories {
+		categoryNames[string(category.Id)] = category.Name
+	}
+
+	streams, err := c.GetLiveStreams("")
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(streams, func(i, j int) bool {
+		return streams[i].Num < streams[j].Num
+	})
+
+	records := make([]*meta.Record, 0, len(streams))
+	for _, stream := range streams {
+		if stream.StreamId == 0 {
+			continue
+		}
+		groupName := categoryNames[string(stream.CategoryId)]
+
+		attributes := &meta.Attributes{}
+		if stream.EpgChannelId != "" {
+			attributes.Set("tvg-id", stream.EpgChannelId)
+		}
+		attributes.Set("tvg-name", stream.Name)
+		if stream.StreamIcon != "" {
+			attributes.Set("tvg-logo", stream.StreamIcon)
+		}
+		if groupName != "" {
+			attributes.Set("group-title", groupName)
+		}
+		if stream.TvArchive != 0 && stream.TvArchiveDuration > 0 {
+			attributes.Set("catchup", "xc")
+			attributes.Set("catchup-days", strconv.Itoa(int(stream.TvArchiveDuration)))
+		}
+
+		var programmes []meta.Programme
+		if epgLimit > 0 && stream.EpgChannelId != "" {
+			programmes, err = c.loadProgrammes(int(stream.StreamId), epgLimit)
+			if err != nil {
+				log.Warnf("Failed to load short epg of %s: %v", stream.Name, err)
+			}
+		}
+
+		records = append(records, &meta.Record{
+			GroupName:  groupName,
+			NameData:   "-1 " + attributes.String() + "," + stream.Name,
+			Url:        c.StreamUrl(int(stream.StreamId), format),
+			RemoteId:   remoteIdPrefix + strconv.Itoa(int(stream.StreamId)),
+			Programmes: programmes,
+		})
+	}
+	return records, nil
+}
+
+func (c *Client) loadProgrammes(streamId int, limit int) ([]meta.Programme, error) {
+	listings, err := c.GetShortEpg(streamId, limit)
+	if err != nil {
+		return nil, err
+	}
+	programmes := make([]meta.Programme, 0, len(listings))
+	for _, listing := range listings {
+		programmes = append(programmes, meta.Programme{
+			Title:       listing.DecodedTitle(),
+			Description: listing.DecodedDescription(),
+			Start:       listing.StartTime(),
+			Stop:        listing.StopTime(),
+		})
+	}
+	return programmes, nil
+}
+
+// LoadMedia builds media directly from panel api without m3u export
+func LoadMedia(client *Client, format string, remoteIdPrefix string, epgLimit int, opts *meta.ReadOptions) (*meta.Media, error) {
+	records, err := client.LoadRecords(format, remoteIdPrefix, epgLimit)
+	if err != nil {
+		return nil, err
+	}
+	return meta.FromRecords(records, opts), nil
+}